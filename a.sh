@@ -16,6 +16,8 @@ grpcurl -plaintext -d '{"group":"scores", "key": "Tom2"}' 127.0.0.1:8002 pb.Grou
 grpcurl -plaintext -d '{"group":"scores", "key": "Tom"}' 127.0.0.1:8003 pb.GroupCache/Get 
 grpcurl -plaintext -d '{"group":"scores", "key": "Tom1"}' 127.0.0.1:8003 pb.GroupCache/Get 
 grpcurl -plaintext -d '{"group":"scores", "key": "Tom2"}' 127.0.0.1:8003 pb.GroupCache/Get 
+grpcurl -plaintext -d '{"group":"scores", "key": "Jerry", "value": "NjQw", "ttl": 60000}' 127.0.0.1:8001 pb.GroupCache/Set 
+grpcurl -plaintext -d '{"group":"scores", "key": "Jerry"}' 127.0.0.1:8002 pb.GroupCache/Get 
 
 kill -9 `lsof -ti:8002`;
 
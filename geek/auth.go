@@ -0,0 +1,382 @@
+package geek
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// metadata keys of the credentials
+const (
+	authorizationHeader = "authorization"
+	hmacKeyIDHeader     = "x-geek-key-id"
+	hmacTimestampHeader = "x-geek-timestamp"
+	hmacSignatureHeader = "x-geek-signature"
+	hmacNonceHeader     = "x-geek-nonce"
+)
+
+const (
+	defaultHMACSkew    = time.Minute
+	nonceSweepInterval = 10 * time.Second
+)
+
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the caller of a call served, by ServerAuth
+type Authenticator interface {
+	// Authenticate returns the principal of the caller, e.g. the name of a user or peer
+	Authenticate(ctx context.Context, method string, req interface{}) (principal string, err error)
+}
+
+// Signer attaches the credentials of self to a call to the peer, by ClientAuth
+type Signer interface {
+	Sign(ctx context.Context, method string, req interface{}) (context.Context, error)
+}
+
+// Permission is what a principal may do on a group
+type Permission int
+
+const (
+	READ   Permission = 1 << iota // Get and MGet
+	WRITE                         // Set
+	DELETE                        // Delete, MDelete and Invalidate
+	ALL    = READ | WRITE | DELETE
+)
+
+// the permission required by each method, the methods not listed are rejected with auth
+var methodPermissions = map[string]Permission{
+	"/pb.GroupCache/Get":        READ,
+	"/pb.GroupCache/MGet":       READ,
+	"/pb.GroupCache/Set":        WRITE,
+	"/pb.GroupCache/Delete":     DELETE,
+	"/pb.GroupCache/MDelete":    DELETE,
+	"/pb.GroupCache/Invalidate": DELETE,
+	"/pb.GroupCache/Peek":       READ,
+	// the permission of Handoff is checked for the group of each entry
+	"/pb.GroupCache/Handoff": WRITE,
+}
+
+// the methods of the reflection service, which are not in methodPermissions
+var reflectionMethodPrefix = "/" + reflectionpb.ServerReflection_ServiceDesc.ServiceName + "/"
+
+// ACL maps group -> principal -> permissions, "*" matches any group or principal.
+// e.g. ACL{"*": {"peer": ALL}, "scores": {"reader": READ}}
+type ACL map[string]map[string]Permission
+
+// Allowed returns true if principal has perm on group
+func (acl ACL) Allowed(principal, group string, perm Permission) bool {
+	for _, g := range []string{group, "*"} {
+		for _, p := range []string{principal, "*"} {
+			if acl[g][p]&perm == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal of the caller authenticated by ServerAuth
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// authUnaryServerInterceptor authenticates the caller, then checks the permission of it on the group by acl,
+// all authenticated callers are allowed if acl is nil
+func authUnaryServerInterceptor(auth Authenticator, acl ACL) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		perm, ok := methodPermissions[info.FullMethod]
+		if !ok {
+			return nil, status.Errorf(codes.PermissionDenied, "method %s is not allowed", info.FullMethod)
+		}
+		principal, err := auth.Authenticate(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "unauthenticated: %v", err)
+		}
+		if acl != nil {
+			group := ""
+			if r, ok := req.(interface{ GetGroup() string }); ok {
+				group = r.GetGroup()
+			}
+			if !acl.Allowed(principal, group, perm) {
+				return nil, status.Errorf(codes.PermissionDenied, "%s is not allowed to %s group %s", principal, info.FullMethod, group)
+			}
+		}
+		return handler(context.WithValue(ctx, principalKey{}, principal), req)
+	}
+}
+
+// authStreamServerInterceptor authenticates the caller of a stream, the request is not signed,
+// so the handler checks the permission on the group of each message by Server.allowed.
+// The reflection service is served without authentication if reflection is true
+func authStreamServerInterceptor(auth Authenticator, reflection bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, ok := methodPermissions[info.FullMethod]; !ok {
+			if reflection && strings.HasPrefix(info.FullMethod, reflectionMethodPrefix) {
+				return handler(srv, ss)
+			}
+			return status.Errorf(codes.PermissionDenied, "method %s is not allowed", info.FullMethod)
+		}
+		principal, err := auth.Authenticate(ss.Context(), info.FullMethod, nil)
+		if err != nil {
+			return status.Errorf(codes.Unauthenticated, "unauthenticated: %v", err)
+		}
+		return handler(srv, &principalStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), principalKey{}, principal)})
+	}
+}
+
+// principalStream carries the principal in the context of the stream
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+// authStreamClientInterceptor signs each stream by signer, without a request
+func authStreamClientInterceptor(signer Signer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := signer.Sign(ctx, method, nil)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// authUnaryClientInterceptor signs each call by signer
+func authUnaryClientInterceptor(signer Signer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := signer.Sign(ctx, method, req)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// TokenAuth authenticates the callers by the static bearer tokens, it's both Authenticator and Signer
+type TokenAuth struct {
+	tokens map[string]string // token -> principal
+	token  string            // the token of self
+}
+
+// NewTokenAuth creates a TokenAuth, tokens maps the accepted tokens to their principals,
+// and token is sent to the peers
+func NewTokenAuth(tokens map[string]string, token string) *TokenAuth {
+	return &TokenAuth{tokens: tokens, token: token}
+}
+
+func (a *TokenAuth) Authenticate(ctx context.Context, _ string, _ interface{}) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return "", errNoCredentials
+	}
+	got := []byte(strings.TrimPrefix(values[0], "Bearer "))
+	for token, principal := range a.tokens {
+		if subtle.ConstantTimeCompare(got, []byte(token)) == 1 {
+			return principal, nil
+		}
+	}
+	return "", errors.New("invalid token")
+}
+
+func (a *TokenAuth) Sign(ctx context.Context, _ string, _ interface{}) (context.Context, error) {
+	return metadata.AppendToOutgoingContext(ctx, authorizationHeader, "Bearer "+a.token), nil
+}
+
+// HMACAuth authenticates the callers by the HMAC-SHA256 signature of the request, the time of signing and a nonce,
+// so that the secret is never sent. Each server remembers the nonces seen within the skew to reject the replayed calls,
+// but the requests are sent in plaintext, use it with TLS if they cross an untrusted network.
+// It's both Authenticator and Signer
+type HMACAuth struct {
+	keys   map[string][]byte // key id -> secret, the key id is the principal
+	keyID  string            // the key of self
+	secret []byte
+	skew   time.Duration // the signatures older or newer than skew are rejected
+	nonces nonceCache
+}
+
+// NewHMACAuth creates a HMACAuth, keys maps the accepted key ids to their secrets, the key id is the principal.
+// keyID and secret sign the calls to the peers
+func NewHMACAuth(keys map[string][]byte, keyID string, secret []byte) *HMACAuth {
+	return &HMACAuth{keys: keys, keyID: keyID, secret: secret, skew: defaultHMACSkew}
+}
+
+// WithSkew sets how far the time of signing may be from now, 1 minute by default.
+// The nonces are remembered until their signatures expire
+func (a *HMACAuth) WithSkew(skew time.Duration) *HMACAuth {
+	a.skew = skew
+	return a
+}
+
+func (a *HMACAuth) Authenticate(ctx context.Context, method string, req interface{}) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keyID, timestamp, signature := first(md, hmacKeyIDHeader), first(md, hmacTimestampHeader), first(md, hmacSignatureHeader)
+	nonce := first(md, hmacNonceHeader)
+	if keyID == "" || timestamp == "" || signature == "" || nonce == "" {
+		return "", errNoCredentials
+	}
+	secret, ok := a.keys[keyID]
+	if !ok {
+		return "", errors.New("unknown key id " + keyID)
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid timestamp")
+	}
+	signedAt := time.Unix(0, ts)
+	if d := time.Since(signedAt); d > a.skew || d < -a.skew {
+		return "", errors.New("signature expired")
+	}
+	expected, err := hmacSign(secret, method, timestamp, nonce, req)
+	if err != nil {
+		return "", err
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, expected) {
+		return "", errors.New("invalid signature")
+	}
+	// checked after the signature, so that the nonces of the forged calls are never remembered
+	if !a.nonces.add(keyID+":"+nonce, signedAt.Add(a.skew)) {
+		return "", errors.New("replayed signature")
+	}
+	return keyID, nil
+}
+
+func (a *HMACAuth) Sign(ctx context.Context, method string, req interface{}) (context.Context, error) {
+	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ctx, err
+	}
+	nonce := hex.EncodeToString(b)
+	signature, err := hmacSign(a.secret, method, timestamp, nonce, req)
+	if err != nil {
+		return ctx, err
+	}
+	return metadata.AppendToOutgoingContext(ctx,
+		hmacKeyIDHeader, a.keyID,
+		hmacTimestampHeader, timestamp,
+		hmacNonceHeader, nonce,
+		hmacSignatureHeader, hex.EncodeToString(signature)), nil
+}
+
+// hmacSign signs the method, timestamp, nonce and the deterministic encoding of req, req is nil for streams
+func hmacSign(secret []byte, method, timestamp, nonce string, req interface{}) ([]byte, error) {
+	var body []byte
+	if req != nil {
+		m, ok := req.(proto.Message)
+		if !ok {
+			return nil, errors.New("request is not a proto message")
+		}
+		var err error
+		body, err = proto.MarshalOptions{Deterministic: true}.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + timestamp + "\n" + nonce + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil), nil
+}
+
+// nonceCache remembers the nonces until they expire, the expired ones are swept at most once per nonceSweepInterval
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // nonce -> expiration time
+	lastSweep time.Time
+}
+
+// add returns false if the nonce has been seen and not expired yet
+func (c *nonceCache) add(nonce string, expirationTime time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+		c.lastSweep = now
+	}
+	if at, ok := c.seen[nonce]; ok && at.After(now) {
+		return false
+	}
+	c.seen[nonce] = expirationTime
+	if now.Sub(c.lastSweep) >= nonceSweepInterval {
+		for n, at := range c.seen {
+			if !at.After(now) {
+				delete(c.seen, n)
+			}
+		}
+		c.lastSweep = now
+	}
+	return true
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// MTLSAuth authenticates the callers by the common name of their verified client certificates,
+// the server must require them, e.g. TLSFiles.ServerConfig(true)
+type MTLSAuth struct{}
+
+func (MTLSAuth) Authenticate(ctx context.Context, _ string, _ interface{}) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errNoCredentials
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", errNoCredentials
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+// AnyAuth tries the authenticators in order, the principal of the first one succeeded is used,
+// e.g. the peers by MTLSAuth and the users by TokenAuth
+func AnyAuth(auths ...Authenticator) Authenticator {
+	return anyAuth(auths)
+}
+
+type anyAuth []Authenticator
+
+func (auths anyAuth) Authenticate(ctx context.Context, method string, req interface{}) (string, error) {
+	lastErr := errNoCredentials
+	for _, auth := range auths {
+		principal, err := auth.Authenticate(ctx, method, req)
+		if err == nil {
+			return principal, nil
+		}
+		// report the invalid credentials rather than the missing ones of other kinds
+		if err != errNoCredentials {
+			lastErr = err
+		}
+	}
+	return "", lastErr
+}
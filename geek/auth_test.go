@@ -0,0 +1,119 @@
+package geek
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/Makonike/geek-cache/geek/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authCodes returns the codes of Get and Delete called by the client with opts
+func authCodes(t *testing.T, addr string, opts ...ClientOptions) (codes.Code, codes.Code) {
+	client, err := NewClient(addr, defaultServiceName, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// the errors of Client keep the message only, so call the grpc client directly
+	_, getErr := client.grpcClient().Get(context.Background(), &pb.Request{Group: "auth", Key: "Tom"})
+	_, delErr := client.grpcClient().Delete(context.Background(), &pb.Request{Group: "auth", Key: "Tom"})
+	return status.Code(getErr), status.Code(delErr)
+}
+
+func TestServerAuth(t *testing.T) {
+	a := assert.New(t)
+	tokens := NewTokenAuth(map[string]string{"reader-token": "reader", "peer-token": "peer"}, "peer-token")
+	keys := NewHMACAuth(map[string][]byte{"signer": []byte("secret")}, "signer", []byte("secret"))
+	_, addr := serveGroup(t, newDBGroup("auth"), ServerAuth(AnyAuth(tokens, keys), ACL{
+		"*":    {"peer": ALL},
+		"auth": {"reader": READ, "signer": READ | DELETE},
+	}))
+
+	get, del := authCodes(t, addr)
+	a.Equal(codes.Unauthenticated, get)
+	a.Equal(codes.Unauthenticated, del)
+	get, del = authCodes(t, addr, ClientAuth(NewTokenAuth(nil, "reader-token")))
+	a.Equal(codes.OK, get)
+	a.Equal(codes.PermissionDenied, del)
+	get, _ = authCodes(t, addr, ClientAuth(NewTokenAuth(nil, "wrong-token")))
+	a.Equal(codes.Unauthenticated, get)
+	// peers authenticate to each other by the same TokenAuth
+	get, del = authCodes(t, addr, ClientAuth(tokens))
+	a.Equal(codes.OK, get)
+	a.Equal(codes.OK, del)
+
+	get, del = authCodes(t, addr, ClientAuth(keys))
+	a.Equal(codes.OK, get)
+	a.Equal(codes.OK, del)
+	get, _ = authCodes(t, addr, ClientAuth(NewHMACAuth(nil, "signer", []byte("wrong"))))
+	a.Equal(codes.Unauthenticated, get)
+}
+
+func TestServerAuth_MTLS(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverConfig, err := ca.issue(t, dir, "server", 2).ServerConfig(true)
+	a.Nil(err)
+	_, addr := serveGroup(t, newDBGroup("auth"), ServerTLS(serverConfig), ServerAuth(MTLSAuth{}, ACL{"auth": {"client": READ}}))
+
+	clientConfig, err := ca.issue(t, dir, "client", 3).ClientConfig()
+	a.Nil(err)
+	get, del := authCodes(t, addr, ClientTLS(clientConfig))
+	a.Equal(codes.OK, get)
+	a.Equal(codes.PermissionDenied, del)
+}
+
+func TestAuthInterceptors_UnknownMethod(t *testing.T) {
+	a := assert.New(t)
+	auth := NewTokenAuth(map[string]string{"token": "peer"}, "token")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(authorizationHeader, "Bearer token"))
+	unary := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	stream := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+
+	_, err := authUnaryServerInterceptor(auth, nil)(ctx, &pb.Request{}, &grpc.UnaryServerInfo{FullMethod: "/pb.GroupCache/Unknown"}, unary)
+	a.Equal(codes.PermissionDenied, status.Code(err))
+	v, err := authUnaryServerInterceptor(auth, nil)(ctx, &pb.Request{}, &grpc.UnaryServerInfo{FullMethod: "/pb.GroupCache/Get"}, unary)
+	a.Nil(err)
+	a.Equal("ok", v)
+
+	reflectionInfo := &grpc.StreamServerInfo{FullMethod: reflectionMethodPrefix + "ServerReflectionInfo"}
+	err = authStreamServerInterceptor(auth, false)(nil, nil, reflectionInfo, stream)
+	a.Equal(codes.PermissionDenied, status.Code(err))
+	err = authStreamServerInterceptor(auth, true)(nil, nil, reflectionInfo, stream)
+	a.Nil(err)
+	err = authStreamServerInterceptor(auth, true)(nil, nil, &grpc.StreamServerInfo{FullMethod: "/pb.GroupCache/Unknown"}, stream)
+	a.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func TestHMACAuth_Replay(t *testing.T) {
+	a := assert.New(t)
+	auth := NewHMACAuth(map[string][]byte{"signer": []byte("secret")}, "signer", []byte("secret"))
+	for _, req := range []interface{}{&pb.Request{Group: "auth", Key: "Tom"}, nil} {
+		ctx, err := auth.Sign(context.Background(), "/pb.GroupCache/Get", req)
+		a.Nil(err)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		incoming := metadata.NewIncomingContext(context.Background(), md)
+		principal, err := auth.Authenticate(incoming, "/pb.GroupCache/Get", req)
+		a.Nil(err)
+		a.Equal("signer", principal)
+		// the same signature is never accepted twice
+		_, err = auth.Authenticate(incoming, "/pb.GroupCache/Get", req)
+		a.NotNil(err)
+	}
+}
+
+func TestACL_Allowed(t *testing.T) {
+	a := assert.New(t)
+	acl := ACL{"*": {"admin": ALL}, "scores": {"*": READ, "writer": WRITE}}
+	a.True(acl.Allowed("admin", "any", DELETE))
+	a.True(acl.Allowed("nobody", "scores", READ))
+	a.False(acl.Allowed("nobody", "scores", WRITE))
+	a.True(acl.Allowed("writer", "scores", WRITE))
+	a.False(acl.Allowed("writer", "other", READ))
+}
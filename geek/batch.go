@@ -0,0 +1,199 @@
+package geek
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// GetResult is the result of a key in GetMany
+type GetResult struct {
+	Key   string
+	Value ByteView
+	Err   error
+}
+
+// DeleteResult is the result of a key in DeleteMany, Err is an *InvalidationError with Success
+// if some peers failed to acknowledge the invalidation of the key, as Delete returns
+type DeleteResult struct {
+	Key     string
+	Success bool
+	Err     error
+}
+
+// GetMany gets the values of keys, the keys owned by the same peer are sent in one request,
+// and the requests to different peers are sent in parallel.
+// The results are in the order of keys.
+func (g *Group) GetMany(keys []string) []GetResult {
+	return g.GetManyContext(context.Background(), keys)
+}
+
+// GetManyContext is like GetMany, the deadline and cancellation of ctx
+// are propagated to the peers and the Getter
+func (g *Group) GetManyContext(ctx context.Context, keys []string) []GetResult {
+	results := make([]GetResult, len(keys))
+	if g.isClosed() {
+		for i, key := range keys {
+			results[i] = GetResult{Key: key, Err: ErrGroupClosed}
+		}
+		return results
+	}
+	local, remote := g.groupByPeer(ctx, keys)
+	var wg sync.WaitGroup
+	get := func(idx int) {
+		defer wg.Done()
+		results[idx].Value, results[idx].Err = g.GetContext(ctx, keys[idx])
+	}
+	// fallback loads the key after its owner failed, from the other replicas if the replication is on, like load does
+	fallback := func(idx int, owner PeerGetter) {
+		defer wg.Done()
+		// through the loader like load does, so that the Getter is called once for the concurrent loads of the key
+		v, err := g.loader.DoContext(ctx, keys[idx], func(ctx context.Context) (interface{}, error) {
+			if replicas, ok := g.pickReplicas(keys[idx]); ok {
+				return g.loadFromReplicas(ctx, keys[idx], withoutPeer(replicas, owner))
+			}
+			return g.getLocally(ctx, keys[idx])
+		})
+		if err != nil {
+			results[idx].Err = err
+			return
+		}
+		results[idx].Value = v.(ByteView)
+	}
+	for peer, idxes := range remote {
+		wg.Add(1)
+		go func(peer PeerGetter, idxes []int) {
+			defer wg.Done()
+			misses := idxes[:0]
+			for _, idx := range idxes {
+				atomic.AddInt64(&g.stats.gets, 1)
+				if v, ok := g.lookupHotCache(keys[idx]); ok {
+					atomic.AddInt64(&g.stats.cacheHits, 1)
+					results[idx].Value = v
+					continue
+				}
+				misses = append(misses, idx)
+			}
+			if len(misses) == 0 {
+				return
+			}
+			idxes = misses
+			batch := make([]string, len(idxes))
+			for i, idx := range idxes {
+				batch[i] = keys[idx]
+			}
+			res, err := peer.MGet(forwardTo(ctx), g.name, batch)
+			if err != nil || len(res) != len(idxes) {
+				atomic.AddInt64(&g.stats.peerErrors, 1)
+				g.logger.Warn("failed to get from peer", "group", g.name, "keys", len(idxes), "err", err)
+				// get them from the replicas or locally like load does
+				wg.Add(len(idxes))
+				for _, idx := range idxes {
+					go fallback(idx, peer)
+				}
+				return
+			}
+			atomic.AddInt64(&g.stats.peerLoads, int64(len(idxes)))
+			for i, idx := range idxes {
+				results[idx].Value, results[idx].Err = res[i].Value, res[i].Err
+				if res[i].Err == nil {
+					g.populateHotCache(keys[idx], res[i].Value)
+				}
+			}
+		}(peer, idxes)
+	}
+	for _, idx := range local {
+		wg.Add(1)
+		go get(idx)
+	}
+	wg.Wait()
+	for i, key := range keys {
+		results[i].Key = key
+	}
+	return results
+}
+
+// DeleteMany deletes keys, the keys owned by the same peer are sent in one request,
+// and the requests to different peers are sent in parallel.
+// The results are in the order of keys.
+func (g *Group) DeleteMany(keys []string) []DeleteResult {
+	return g.DeleteManyContext(context.Background(), keys)
+}
+
+// DeleteManyContext is like DeleteMany, the deadline and cancellation of ctx are propagated to the peers
+func (g *Group) DeleteManyContext(ctx context.Context, keys []string) []DeleteResult {
+	results := make([]DeleteResult, len(keys))
+	if g.isClosed() {
+		for i, key := range keys {
+			results[i] = DeleteResult{Key: key, Err: ErrGroupClosed}
+		}
+		return results
+	}
+	local, remote := g.groupByPeer(ctx, keys)
+	var wg sync.WaitGroup
+	del := func(idx int) {
+		defer wg.Done()
+		results[idx].Success, results[idx].Err = g.DeleteContext(ctx, keys[idx])
+	}
+	for peer, idxes := range remote {
+		wg.Add(1)
+		go func(peer PeerGetter, idxes []int) {
+			defer wg.Done()
+			batch := make([]string, len(idxes))
+			for i, idx := range idxes {
+				batch[i] = keys[idx]
+				g.removeHotCache(keys[idx])
+			}
+			res, err := peer.MDelete(forwardTo(ctx), g.name, batch)
+			if err == nil && len(res) != len(idxes) {
+				err = fmt.Errorf("peer returned %d results for %d keys", len(res), len(idxes))
+			}
+			for i, idx := range idxes {
+				if err != nil {
+					results[idx].Err = err
+					continue
+				}
+				results[idx].Success, results[idx].Err = res[i].Success, res[i].Err
+			}
+		}(peer, idxes)
+	}
+	for _, idx := range local {
+		wg.Add(1)
+		go del(idx)
+	}
+	wg.Wait()
+	for i, key := range keys {
+		results[i].Key = key
+	}
+	return results
+}
+
+// groupByPeer groups the indexes of keys by the peers which own them,
+// local contains the keys owned by self and the keys which are handled by Get or Delete directly,
+// e.g. all the keys forwarded by a peer
+func (g *Group) groupByPeer(ctx context.Context, keys []string) (local []int, remote map[PeerGetter][]int) {
+	remote = make(map[PeerGetter][]int)
+	forwarded := isForwarded(ctx)
+	for i, key := range keys {
+		if g.peers != nil && key != "" && !forwarded {
+			if peer, ok, isSelf := g.peers.PickPeer(key); ok && !isSelf {
+				remote[peer] = append(remote[peer], i)
+				continue
+			}
+		}
+		local = append(local, i)
+	}
+	return local, remote
+}
+
+// withoutPeer returns the peers except peer, e.g. the replicas of a key except the owner which has failed
+func withoutPeer(peers []PeerGetter, peer PeerGetter) []PeerGetter {
+	others := make([]PeerGetter, 0, len(peers))
+	for _, p := range peers {
+		if p != peer {
+			others = append(others, p)
+		}
+	}
+	return others
+}
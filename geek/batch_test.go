@@ -0,0 +1,285 @@
+package geek
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePeer records the batches it received
+type fakePeer struct {
+	mu          sync.Mutex
+	name        string
+	batches     [][]string
+	invalidated []string
+	handoffs    [][]string
+	fail        bool
+}
+
+func (p *fakePeer) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	if p.fail {
+		return nil, fmt.Errorf("peer %s is down", p.name)
+	}
+	return []byte(p.name + key), nil
+}
+
+func (p *fakePeer) Delete(ctx context.Context, group string, key string) (bool, error) {
+	return true, nil
+}
+
+func (p *fakePeer) Set(ctx context.Context, group string, key string, value []byte, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (p *fakePeer) SetReplica(ctx context.Context, group string, key string, value []byte, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (p *fakePeer) MGet(ctx context.Context, group string, keys []string) ([]GetResult, error) {
+	p.mu.Lock()
+	p.batches = append(p.batches, keys)
+	p.mu.Unlock()
+	if p.fail {
+		return nil, fmt.Errorf("peer %s is down", p.name)
+	}
+	results := make([]GetResult, len(keys))
+	for i, key := range keys {
+		results[i] = GetResult{Key: key, Value: ByteView{[]byte(p.name + key)}}
+	}
+	return results, nil
+}
+
+func (p *fakePeer) MDelete(ctx context.Context, group string, keys []string) ([]DeleteResult, error) {
+	p.mu.Lock()
+	p.batches = append(p.batches, keys)
+	p.mu.Unlock()
+	if p.fail {
+		return nil, fmt.Errorf("peer %s is down", p.name)
+	}
+	results := make([]DeleteResult, len(keys))
+	for i, key := range keys {
+		results[i] = DeleteResult{Key: key, Success: true}
+	}
+	return results, nil
+}
+
+func (p *fakePeer) Invalidate(ctx context.Context, group string, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invalidated = append(p.invalidated, key)
+	if p.fail {
+		return fmt.Errorf("peer %s is down", p.name)
+	}
+	return nil
+}
+
+func (p *fakePeer) Handoff(ctx context.Context, group string, entries []HandoffEntry) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail {
+		return 0, fmt.Errorf("peer %s is down", p.name)
+	}
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	p.handoffs = append(p.handoffs, keys)
+	return len(entries), nil
+}
+
+func (p *fakePeer) Peek(ctx context.Context, group string, key string) ([]byte, time.Time, error) {
+	return nil, time.Time{}, ErrNotFound
+}
+
+// fakePicker picks the peer by the first letter of key, keys starting with "s" are owned by self
+type fakePicker struct {
+	peers map[byte]*fakePeer
+}
+
+func (p *fakePicker) PickPeer(key string) (PeerGetter, bool, bool) {
+	if key[0] == 's' {
+		return nil, true, true
+	}
+	if peer, ok := p.peers[key[0]]; ok {
+		return peer, true, false
+	}
+	return nil, false, false
+}
+
+func (p *fakePicker) Peers() map[string]PeerGetter {
+	peers := make(map[string]PeerGetter)
+	for _, peer := range p.peers {
+		peers[peer.name] = peer
+	}
+	return peers
+}
+
+func TestGroup_GetMany(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("batch", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			if key == "s-unknown" {
+				return nil, false, time.Time{}
+			}
+			return []byte("db" + key), true, time.Time{}
+		}))
+	picker := &fakePicker{peers: map[byte]*fakePeer{
+		'a': {name: "A"},
+		'b': {name: "B"},
+		'c': {name: "C", fail: true},
+	}}
+	g.RegisterPeers(picker)
+
+	keys := []string{"a1", "s1", "b1", "a2", "", "c1", "s-unknown", "b2", "a3"}
+	results := g.GetMany(keys)
+	a.Equal(len(keys), len(results))
+	expected := []string{"Aa1", "dbs1", "Bb1", "Aa2", "", "dbc1", "", "Bb2", "Aa3"}
+	for i, r := range results {
+		a.Equal(keys[i], r.Key)
+		a.Equal(expected[i], r.Value.String())
+	}
+	a.NotNil(results[4].Err)
+	a.NotNil(results[6].Err)
+	// one request per peer
+	a.Equal([][]string{{"a1", "a2", "a3"}}, picker.peers['a'].batches)
+	a.Equal([][]string{{"b1", "b2"}}, picker.peers['b'].batches)
+	a.Equal([][]string{{"c1"}}, picker.peers['c'].batches)
+}
+
+func TestGroup_GetManyFallback(t *testing.T) {
+	a := assert.New(t)
+	var loads int64
+	g := NewGroup("batch-fallback", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			atomic.AddInt64(&loads, 1)
+			// slow enough for the loads of the key to overlap
+			time.Sleep(50 * time.Millisecond)
+			return []byte("db" + key), true, time.Time{}
+		}), PrivateForTesting())
+	g.RegisterPeers(&fakePicker{peers: map[byte]*fakePeer{'c': {name: "C", fail: true}}})
+
+	// the keys of the failed peer are loaded through the loader
+	results := g.GetMany([]string{"c1", "c1", "c1"})
+	for _, r := range results {
+		a.Nil(r.Err)
+		a.Equal("dbc1", r.Value.String())
+	}
+	a.Equal(int64(1), atomic.LoadInt64(&loads))
+}
+
+// replicaPicker is a fakePicker which places the keys on their owner and replica
+type replicaPicker struct {
+	*fakePicker
+	replica *fakePeer
+}
+
+func (p *replicaPicker) PickReplicas(key string, n int) []PeerGetter {
+	owner, _, _ := p.PickPeer(key)
+	return []PeerGetter{owner, p.replica}
+}
+
+func TestGroup_GetManyReplicaFallback(t *testing.T) {
+	a := assert.New(t)
+	var loads int64
+	g := NewGroup("batch-replica", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			atomic.AddInt64(&loads, 1)
+			return []byte("db" + key), true, time.Time{}
+		}), Replication(2), PrivateForTesting())
+	g.RegisterPeers(&replicaPicker{
+		fakePicker: &fakePicker{peers: map[byte]*fakePeer{'c': {name: "C", fail: true}}},
+		replica:    &fakePeer{name: "R"},
+	})
+
+	// the keys of the failed owner are got from the replica, the Getter is not called
+	results := g.GetMany([]string{"c1", "c2"})
+	for i, r := range results {
+		a.Nil(r.Err)
+		a.Equal(fmt.Sprintf("Rc%d", i+1), r.Value.String())
+	}
+	a.Equal(int64(0), atomic.LoadInt64(&loads))
+}
+
+func TestGroup_DeleteMany(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("batch", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}))
+	picker := &fakePicker{peers: map[byte]*fakePeer{
+		'a': {name: "A"},
+		'c': {name: "C", fail: true},
+	}}
+	g.RegisterPeers(picker)
+
+	keys := []string{"a1", "s1", "c1", "a2"}
+	results := g.DeleteMany(keys)
+	a.True(results[0].Success)
+	a.True(results[1].Success)
+	a.False(results[2].Success)
+	a.NotNil(results[2].Err)
+	a.True(results[3].Success)
+	a.Equal([][]string{{"a1", "a2"}}, picker.peers['a'].batches)
+}
+
+func TestGroup_HotKeyCache(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("hot", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}), HotKeyCache(1<<10, time.Second, 1))
+	peer := &countingPeer{fakePeer: fakePeer{name: "A"}}
+	g.RegisterPeers(&singlePeerPicker{peer: peer})
+
+	// the value from peer is kept in hot cache
+	for i := 0; i < 3; i++ {
+		v, err := g.Get("a1")
+		a.Nil(err)
+		a.Equal("Aa1", v.String())
+	}
+	a.Equal(1, peer.gets)
+	a.Equal(CacheStats{Gets: 3, Hits: 2, Bytes: 5, Items: 1}, g.CacheStats(HotCache))
+	a.Equal(int64(0), g.CacheStats(MainCache).Gets)
+
+	// batch get uses hot cache too
+	results := g.GetMany([]string{"a1", "a2"})
+	a.Equal("Aa1", results[0].Value.String())
+	a.Equal("Aa2", results[1].Value.String())
+	a.Equal([][]string{{"a2"}}, peer.batches)
+
+	// delete drops the hot copy
+	_, err := g.Delete("a1")
+	a.Nil(err)
+	_, _ = g.Get("a1")
+	a.Equal(2, peer.gets)
+
+	// expired after ttl
+	time.Sleep(time.Second)
+	_, _ = g.Get("a1")
+	a.Equal(3, peer.gets)
+}
+
+// countingPeer counts the single gets
+type countingPeer struct {
+	fakePeer
+	gets int
+}
+
+func (p *countingPeer) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	p.gets++
+	return p.fakePeer.Get(ctx, group, key)
+}
+
+// singlePeerPicker picks the same peer for all keys, it doesn't implement the optional interfaces
+type singlePeerPicker struct {
+	peer PeerGetter
+}
+
+func (p *singlePeerPicker) PickPeer(key string) (PeerGetter, bool, bool) {
+	return p.peer, true, false
+}
@@ -2,41 +2,94 @@ package geek
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	c "github.com/Makonike/geek-cache/geek/cache"
 )
 
-// cache 实例化lru，封装get和add。
+// cache 实例化缓存算法，封装get和add。
+// the store is thread safe itself, so there is no lock here,
+// otherwise all calls would serialize on it even if the store is sharded
 type cache struct {
-	lock       sync.RWMutex
-	lruCache   c.Cache
+	once       sync.Once
+	store      c.Cache
+	loaded     int32 // 1 once the store is created, set by atomic
 	cacheBytes int64
+	algorithm  c.Algorithm
+	policy     c.MaxMemoryPolicy
+	shards     int
+	expiry     time.Duration // how often the expired keys are removed, the default of the store if 0
+	nget, nhit int64         // counted by atomic
+	nevicted   int64         // evicted when cacheBytes is exceeded, counted by atomic
+	nexpired   int64         // evicted when expired, counted by atomic
 }
 
-func (cache *cache) lruCacheLazyLoadIfNeed() {
-	if cache.lruCache == nil {
-		cache.lock.Lock()
-		defer cache.lock.Unlock()
-		if cache.lruCache == nil {
-			cache.lruCache = c.NewLRUCache(cache.cacheBytes)
-		}
+// CacheType represents a type of cache of a group
+type CacheType int
+
+const (
+	// MainCache is the cache of the keys owned by this peer
+	MainCache CacheType = iota + 1
+	// HotCache is the cache of hot keys owned by other peers
+	HotCache
+	// NegativeCache is the cache of the keys not found by the Getter
+	NegativeCache
+)
+
+// CacheStats are the statistics of a cache
+type CacheStats struct {
+	Gets        int64
+	Hits        int64
+	Evictions   int64 // keys evicted because the cache is full
+	Expirations int64 // keys evicted because they are expired
+	Bytes       int64 // memory in use
+	Items       int64 // number of keys
+}
+
+func (cache *cache) stats() CacheStats {
+	stats := CacheStats{
+		Gets:        atomic.LoadInt64(&cache.nget),
+		Hits:        atomic.LoadInt64(&cache.nhit),
+		Evictions:   atomic.LoadInt64(&cache.nevicted),
+		Expirations: atomic.LoadInt64(&cache.nexpired),
+	}
+	// the hot cache and the negative cache are disabled if their cacheBytes is 0
+	if cache.cacheBytes > 0 {
+		store := cache.storeLazyLoadIfNeed()
+		stats.Bytes = store.Bytes()
+		stats.Items = int64(store.Len())
+	}
+	return stats
+}
+
+func (cache *cache) storeLazyLoadIfNeed() c.Cache {
+	cache.once.Do(func() {
+		cache.store = c.New(cache.algorithm, cache.cacheBytes, c.Policy(cache.policy), c.Shards(cache.shards),
+			c.OnEvicted(cache.onEvicted), c.ExpiryInterval(cache.expiry))
+		atomic.StoreInt32(&cache.loaded, 1)
+	})
+	return cache.store
+}
+
+// onEvicted is called with the lock of the store held, so it only counts
+func (cache *cache) onEvicted(_ string, _ c.Value, reason c.EvictionReason) {
+	if reason == c.EXPIRED {
+		atomic.AddInt64(&cache.nexpired, 1)
+	} else {
+		atomic.AddInt64(&cache.nevicted, 1)
 	}
 }
 
 func (cache *cache) add(key string, value ByteView) {
 	// lazy load
-	cache.lruCacheLazyLoadIfNeed()
-	cache.lruCache.Add(key, value)
+	cache.storeLazyLoadIfNeed().Add(key, value)
 }
 
 func (cache *cache) get(key string) (value ByteView, ok bool) {
-	cache.lock.RLock()
-	defer cache.lock.RUnlock()
-	if cache.lruCache == nil {
-		return
-	}
-	if v, find := cache.lruCache.Get(key); find {
+	atomic.AddInt64(&cache.nget, 1)
+	if v, find := cache.storeLazyLoadIfNeed().Get(key); find {
+		atomic.AddInt64(&cache.nhit, 1)
 		return v.(ByteView), true
 	}
 	return
@@ -44,15 +97,17 @@ func (cache *cache) get(key string) (value ByteView, ok bool) {
 
 func (cache *cache) addWithExpiration(key string, value ByteView, expirationTime time.Time) {
 	// lazy load
-	cache.lruCacheLazyLoadIfNeed()
-	cache.lruCache.AddWithExpiration(key, value, expirationTime)
+	cache.storeLazyLoadIfNeed().AddWithExpiration(key, value, expirationTime)
 }
 
 func (cache *cache) delete(key string) bool {
-	cache.lock.Lock()
-	defer cache.lock.Unlock()
-	if cache.lruCache == nil {
-		return true
+	return cache.storeLazyLoadIfNeed().Delete(key)
+}
+
+// close stops the background work of the store and purges it, if the store has been created,
+// e.g. even the main cache of cacheBytes 0 is created by the lookups
+func (cache *cache) close() {
+	if atomic.LoadInt32(&cache.loaded) == 1 {
+		cache.store.Close()
 	}
-	return cache.lruCache.Delete(key)
 }
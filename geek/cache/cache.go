@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type Cache interface {
+	Get(key string) (Value, bool)
+	Add(key string, value Value)
+	AddWithExpiration(key string, value Value, expirationTime time.Time)
+	Delete(key string) bool
+	Len() int     // number of keys
+	Bytes() int64 // memory in use
+	// Peek is like Get, but doesn't change the order of eviction, and returns the expiration time too
+	Peek(key string) (Value, time.Time, bool)
+	// Range calls fn for each key not expired until it returns false, fn is called with the lock held
+	Range(fn func(key string, value Value, expirationTime time.Time) bool)
+	// Close stops the periodic clean and purges all keys, the cache is still usable but never cleaned
+	Close()
+}
+
+// EvictedFunc is called when a key is evicted, not when it's deleted
+type EvictedFunc func(key string, value Value, reason EvictionReason)
+
+type Value interface {
+	Len() int // return data size
+}
+
+// options shared by all kinds of cache
+type options struct {
+	policy    MaxMemoryPolicy // only used by the lru cache
+	shards    int             // number of segments, the cache is not sharded if it's less than 2
+	onEvicted EvictedFunc
+	// the expired keys are removed every expiryInterval, without waiting for Get
+	expiryInterval time.Duration
+}
+
+type CacheOptions func(*options)
+
+// Policy sets the eviction policy of the lru cache, ALLKEYS_LRU by default.
+// The lfu and tinylfu caches panic with other policies
+func Policy(policy MaxMemoryPolicy) CacheOptions {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// Shards splits the cache into n segments with their own locks,
+// keys are hashed across the segments and maxBytes is divided between them
+func Shards(n int) CacheOptions {
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// OnEvicted sets the callback when a key is evicted, it's called with the lock of the cache held
+func OnEvicted(fn EvictedFunc) CacheOptions {
+	return func(o *options) {
+		o.onEvicted = fn
+	}
+}
+
+// ExpiryInterval sets how often the expired keys are removed, 1 second by default.
+// The keys closest to their deadlines are removed first, and at most 1024 keys for each tick
+func ExpiryInterval(interval time.Duration) CacheOptions {
+	return func(o *options) {
+		o.expiryInterval = interval
+	}
+}
+
+func newOptions(opts ...CacheOptions) options {
+	o := options{
+		policy:         ALLKEYS_LRU,
+		expiryInterval: defaultExpiryInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.expiryInterval <= 0 {
+		o.expiryInterval = defaultExpiryInterval
+	}
+	return o
+}
+
+// New creates a cache with the specific algorithm
+func New(algorithm Algorithm, maxBytes int64, opts ...CacheOptions) Cache {
+	o := newOptions(opts...)
+	if o.shards > 1 {
+		return newShardedCache(algorithm, maxBytes, o)
+	}
+	return newCache(algorithm, maxBytes, o)
+}
+
+func newCache(algorithm Algorithm, maxBytes int64, o options) Cache {
+	switch algorithm {
+	case LFU:
+		return newLFUCache(maxBytes, o)
+	case TINY_LFU:
+		return newTinyLFUCache(maxBytes, o)
+	default:
+		return newLRUCache(maxBytes, o)
+	}
+}
+
+// run clean every interval to remove the expired keys until stop is called
+func startPeriodicClean(interval time.Duration, clean func()) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				clean()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
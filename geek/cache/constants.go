@@ -1,10 +1,40 @@
 package cache
 
+// MaxMemoryPolicy decides which key is evicted when the cache exceeds its maxBytes,
+// the same as the maxmemory-policy of redis. It only works with LRU,
+// the lfu and tinylfu caches panic with any policy other than ALLKEYS_LRU
 type MaxMemoryPolicy int
 
 const (
-	VOLATILE_LRU    MaxMemoryPolicy = 1
-	VOLATILE_RANDOM MaxMemoryPolicy = 2
-	ALLKEYS_LRU     MaxMemoryPolicy = 3
-	ALLKEYS_RANDOM  MaxMemoryPolicy = 4
+	VOLATILE_LRU    MaxMemoryPolicy = 1 // evict the least recently used key among keys with an expiration
+	VOLATILE_RANDOM MaxMemoryPolicy = 2 // evict a random key among keys with an expiration
+	ALLKEYS_LRU     MaxMemoryPolicy = 3 // evict the least recently used key among all keys
+	ALLKEYS_RANDOM  MaxMemoryPolicy = 4 // evict a random key among all keys
 )
+
+// Algorithm is the replacement algorithm of a cache
+type Algorithm int
+
+const (
+	LRU      Algorithm = 1 // least recently used
+	LFU      Algorithm = 2 // least frequently used
+	TINY_LFU Algorithm = 3 // W-TinyLFU, a windowed lru in front of a segmented lru admitted by a count-min sketch
+)
+
+// EvictionReason is why a key is evicted
+type EvictionReason int
+
+const (
+	CAPACITY EvictionReason = 1 // the cache exceeds its maxBytes
+	EXPIRED  EvictionReason = 2 // the key is expired
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case CAPACITY:
+		return "capacity"
+	case EXPIRED:
+		return "expired"
+	}
+	return "unknown"
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+const (
+	defaultExpiryInterval = time.Second
+	maxExpiredPerTick     = 1024 // the entries popped by each tick at most, the rest are left to the next tick
+	minCompactSize        = 64
+)
+
+// expiryIndex is a min-heap of the expiration times, so the expired keys are found without scanning.
+// The entries are not removed when their keys are deleted or updated,
+// they're checked against the expiration time in the cache when popped
+type expiryIndex []expiryEntry
+
+type expiryEntry struct {
+	key string
+	at  time.Time
+}
+
+func (x expiryIndex) Len() int            { return len(x) }
+func (x expiryIndex) Less(i, j int) bool  { return x[i].at.Before(x[j].at) }
+func (x expiryIndex) Swap(i, j int)       { x[i], x[j] = x[j], x[i] }
+func (x *expiryIndex) Push(e interface{}) { *x = append(*x, e.(expiryEntry)) }
+
+func (x *expiryIndex) Pop() interface{} {
+	old := *x
+	e := old[len(old)-1]
+	*x = old[:len(old)-1]
+	return e
+}
+
+func (x *expiryIndex) add(key string, at time.Time) {
+	heap.Push(x, expiryEntry{key: key, at: at})
+}
+
+// popExpired pops at most n entries expired before now, the earliest first,
+// and calls fn for the keys which still expire at the time of their entries
+func (x *expiryIndex) popExpired(now time.Time, n int, expires map[string]time.Time, fn func(key string)) {
+	for i := 0; i < n && len(*x) > 0 && !(*x)[0].at.After(now); i++ {
+		e := heap.Pop(x).(expiryEntry)
+		if at, ok := expires[e.key]; ok && at.Equal(e.at) {
+			fn(e.key)
+		}
+	}
+}
+
+// compact rebuilds the index from expires when most of the entries are stale
+func (x *expiryIndex) compact(expires map[string]time.Time) {
+	if len(*x) <= minCompactSize || len(*x) <= 2*len(expires) {
+		return
+	}
+	entries := make(expiryIndex, 0, len(expires))
+	for key, at := range expires {
+		entries = append(entries, expiryEntry{key: key, at: at})
+	}
+	heap.Init(&entries)
+	*x = entries
+}
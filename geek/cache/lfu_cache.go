@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lfuCache evicts the least frequently used key,
+// and the least recently used one among the keys with the same frequency
+type lfuCache struct {
+	lock      sync.Mutex
+	cacheMap  map[string]*list.Element // map cache, the element is in the list of its frequency
+	expires   map[string]time.Time     // The expiration time of key
+	index     expiryIndex              // The keys ordered by the expiration time
+	freqs     map[int]*list.List       // frequency -> linked list, the front is the least recently used
+	minFreq   int                      // The minimum frequency in freqs
+	OnEvicted EvictedFunc              // The callback function when a record is evicted
+	maxBytes  int64                    // The maximum memory allowed
+	nbytes    int64                    // The memory is currently in use
+	stopClean func()                   // stops the periodic clean
+}
+
+type lfuEntry struct {
+	key   string
+	value Value
+	freq  int
+}
+
+func NewLFUCache(maxSize int64, opts ...CacheOptions) *lfuCache {
+	return newLFUCache(maxSize, newOptions(opts...))
+}
+
+func newLFUCache(maxSize int64, o options) *lfuCache {
+	if o.policy != ALLKEYS_LRU {
+		panic("the memory policy only works with LRU")
+	}
+	answer := lfuCache{
+		cacheMap:  make(map[string]*list.Element),
+		expires:   make(map[string]time.Time),
+		freqs:     make(map[int]*list.List),
+		maxBytes:  maxSize,
+		OnEvicted: o.onEvicted,
+	}
+	answer.stopClean = startPeriodicClean(o.expiryInterval, answer.expire)
+	return &answer
+}
+
+func (c *lfuCache) Get(key string) (Value, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.cacheMap[key]
+	if !ok {
+		return nil, false
+	}
+	kv := e.Value.(*lfuEntry)
+	// check for expiration
+	if expirationTime, ok := c.expires[key]; ok && expirationTime.Before(time.Now()) {
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(key, kv.value, EXPIRED)
+		}
+		return nil, false
+	}
+	c.increment(e)
+	return kv.value, true
+}
+
+// add a key-value
+func (c *lfuCache) Add(key string, value Value) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.baseAdd(key, value)
+	delete(c.expires, key)
+	c.freeMemoryIfNeeded()
+}
+
+// add a key-value whth expiration
+func (c *lfuCache) AddWithExpiration(key string, value Value, expirationTime time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.baseAdd(key, value)
+	c.expires[key] = expirationTime
+	c.index.add(key, expirationTime)
+	c.freeMemoryIfNeeded()
+}
+
+// delete a key-value by key
+func (c *lfuCache) Delete(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.cacheMap[key]; ok {
+		c.removeElement(e)
+	}
+	return true
+}
+
+func (c *lfuCache) baseAdd(key string, value Value) {
+	// Check whether the key already exists
+	if e, ok := c.cacheMap[key]; ok {
+		kv := e.Value.(*lfuEntry)
+		c.nbytes += int64(value.Len() - kv.value.Len())
+		// update value
+		kv.value = value
+		c.increment(e)
+		return
+	}
+	c.nbytes += int64(len(key) + value.Len())
+	// make room before adding, otherwise the new key with the lowest frequency will be evicted first
+	c.freeMemoryIfNeeded()
+	c.cacheMap[key] = c.listOf(1).PushBack(&lfuEntry{key: key, value: value, freq: 1})
+	c.minFreq = 1
+}
+
+// lockless !!! move the element to the list of the next frequency
+func (c *lfuCache) increment(e *list.Element) {
+	kv := e.Value.(*lfuEntry)
+	l := c.freqs[kv.freq]
+	l.Remove(e)
+	if l.Len() == 0 {
+		delete(c.freqs, kv.freq)
+		if c.minFreq == kv.freq {
+			c.minFreq++
+		}
+	}
+	kv.freq++
+	c.cacheMap[kv.key] = c.listOf(kv.freq).PushBack(kv)
+}
+
+// lockless !!! get the list of the frequency, create it if not exists
+func (c *lfuCache) listOf(freq int) *list.List {
+	l, ok := c.freqs[freq]
+	if !ok {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+// lockless !!! free Memory when the memory is insufficient
+func (c *lfuCache) freeMemoryIfNeeded() {
+	for c.nbytes > c.maxBytes && len(c.cacheMap) > 0 {
+		l, ok := c.freqs[c.minFreq]
+		if !ok {
+			// minFreq is stale after deleting, find it again
+			c.minFreq = 0
+			for freq := range c.freqs {
+				if c.minFreq == 0 || freq < c.minFreq {
+					c.minFreq = freq
+				}
+			}
+			l = c.freqs[c.minFreq]
+		}
+		e := l.Front()
+		kv := e.Value.(*lfuEntry)
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value, CAPACITY)
+		}
+	}
+}
+
+// lockless !!! remove the element from the list of its frequency and the maps
+func (c *lfuCache) removeElement(e *list.Element) {
+	kv := e.Value.(*lfuEntry)
+	l := c.freqs[kv.freq]
+	l.Remove(e)
+	if l.Len() == 0 {
+		delete(c.freqs, kv.freq)
+	}
+	delete(c.cacheMap, kv.key)
+	delete(c.expires, kv.key)
+	c.nbytes -= int64(len(kv.key) + kv.value.Len())
+}
+
+// expire removes the expired keys closest to their deadlines, the work of each tick is bounded by maxExpiredPerTick
+func (c *lfuCache) expire() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.index.popExpired(time.Now(), maxExpiredPerTick, c.expires, func(key string) {
+		e := c.cacheMap[key]
+		kv := e.Value.(*lfuEntry)
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value, EXPIRED)
+		}
+	})
+	c.index.compact(c.expires)
+}
+
+// Peek is like Get, but doesn't change the order of eviction, and returns the expiration time too
+func (c *lfuCache) Peek(key string) (Value, time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.cacheMap[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	expirationTime := c.expires[key]
+	if !expirationTime.IsZero() && expirationTime.Before(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	return e.Value.(*lfuEntry).value, expirationTime, true
+}
+
+// Range calls fn for each key not expired until it returns false, fn is called with the lock held
+func (c *lfuCache) Range(fn func(key string, value Value, expirationTime time.Time) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	for key, e := range c.cacheMap {
+		expirationTime := c.expires[key]
+		if !expirationTime.IsZero() && expirationTime.Before(now) {
+			continue
+		}
+		if !fn(key, e.Value.(*lfuEntry).value, expirationTime) {
+			return
+		}
+	}
+}
+
+// Close stops the periodic clean and purges all keys without calling OnEvicted
+func (c *lfuCache) Close() {
+	c.stopClean()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cacheMap = make(map[string]*list.Element)
+	c.expires = make(map[string]time.Time)
+	c.index = nil
+	c.freqs = make(map[int]*list.List)
+	c.minFreq = 0
+	c.nbytes = 0
+}
+
+func (c *lfuCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.cacheMap)
+}
+
+func (c *lfuCache) Bytes() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.nbytes
+}
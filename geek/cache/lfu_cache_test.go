@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 检测lfu算法
+func TestLFUCache_FreeMemory(t *testing.T) {
+	a := assert.New(t)
+	cache := NewLFUCache(90)
+	for i := 0; i < 9; i++ {
+		cache.Add(strconv.Itoa(i), &testValue{"123456789"})
+	}
+	// 除了key为0的, 其余的都被访问过
+	for i := 1; i < 9; i++ {
+		_, f := cache.Get(strconv.Itoa(i))
+		a.True(f)
+	}
+	// key为0的访问频率最低, 被淘汰
+	cache.Add("a", &testValue{"123456789"})
+	_, f0 := cache.Get("0")
+	a.False(f0)
+	v1, f1 := cache.Get("1")
+	a.True(f1)
+	a.Equal(v1.(*testValue).b, "123456789")
+	// 新加入的key也会被保留
+	_, fa := cache.Get("a")
+	a.True(fa)
+	// 频率相同时淘汰最久未使用的, key为2的被淘汰
+	cache.Add("b", &testValue{"123456789"})
+	_, f2 := cache.Get("2")
+	a.False(f2)
+	a.LessOrEqual(cache.nbytes, cache.maxBytes)
+}
+
+// 测试超时和删除
+func TestLFUCache_ExpirationAndDelete(t *testing.T) {
+	a := assert.New(t)
+	cache := NewLFUCache(100)
+	cache.AddWithExpiration("1", &testValue{"123456789"}, time.Now().Add(time.Second))
+	cache.Add("2", &testValue{"123456789"})
+	v1, f1 := cache.Get("1")
+	a.True(f1)
+	a.Equal("123456789", v1.(*testValue).b)
+	time.Sleep(time.Second)
+	_, f1 = cache.Get("1")
+	a.False(f1)
+	cache.Delete("2")
+	_, f2 := cache.Get("2")
+	a.False(f2)
+	a.Equal(int64(0), cache.nbytes)
+	a.Equal(0, len(cache.freqs))
+}
+
+// 检测热点key不会被一次性扫描冲掉
+func TestTinyLFUCache_ScanResistant(t *testing.T) {
+	a := assert.New(t)
+	cache := NewTinyLFUCache(1000)
+	// 10个热点key
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 10; i++ {
+			key := "hot" + strconv.Itoa(i)
+			if _, ok := cache.Get(key); !ok {
+				cache.Add(key, &testValue{"123456789"})
+			}
+		}
+	}
+	// 一次性扫描大量冷数据
+	for i := 0; i < 1000; i++ {
+		key := "cold" + strconv.Itoa(i)
+		if _, ok := cache.Get(key); !ok {
+			cache.Add(key, &testValue{"123456789"})
+		}
+	}
+	for i := 0; i < 10; i++ {
+		_, ok := cache.Get("hot" + strconv.Itoa(i))
+		a.True(ok)
+	}
+	a.LessOrEqual(cache.bytes[segWindow]+cache.bytes[segProbation]+cache.bytes[segProtected], cache.maxBytes)
+}
+
+// 测试超时和删除
+func TestTinyLFUCache_ExpirationAndDelete(t *testing.T) {
+	a := assert.New(t)
+	cache := NewTinyLFUCache(100)
+	cache.AddWithExpiration("1", &testValue{"123456789"}, time.Now().Add(time.Second))
+	cache.Add("2", &testValue{"123456789"})
+	v1, f1 := cache.Get("1")
+	a.True(f1)
+	a.Equal("123456789", v1.(*testValue).b)
+	time.Sleep(time.Second)
+	_, f1 = cache.Get("1")
+	a.False(f1)
+	cache.Delete("2")
+	_, f2 := cache.Get("2")
+	a.False(f2)
+	a.Equal(0, len(cache.cacheMap))
+	a.Equal([3]int64{}, cache.bytes)
+}
+
+func TestTinyLFUCache_AddIncrementsSketch(t *testing.T) {
+	a := assert.New(t)
+	cache := NewTinyLFUCache(100)
+	cache.Add("1", &testValue{"1"})
+	cache.AddWithExpiration("2", &testValue{"2"}, time.Now().Add(time.Minute))
+	a.Equal(uint8(1), cache.sketch.estimate("1"))
+	a.Equal(uint8(1), cache.sketch.estimate("2"))
+}
+
+func TestNew_PolicyOnlyWithLRU(t *testing.T) {
+	a := assert.New(t)
+	a.Panics(func() { New(LFU, 100, Policy(VOLATILE_LRU)) })
+	a.Panics(func() { New(TINY_LFU, 100, Policy(ALLKEYS_RANDOM), Shards(2)) })
+	a.NotPanics(func() { New(LRU, 100, Policy(VOLATILE_LRU)).Close() })
+	a.NotPanics(func() { New(TINY_LFU, 100).Close() })
+}
+
+func TestCMSketch(t *testing.T) {
+	a := assert.New(t)
+	s := newCMSketch(16)
+	for i := 0; i < 5; i++ {
+		s.increment("hot")
+	}
+	s.increment("cold")
+	a.GreaterOrEqual(s.estimate("hot"), uint8(5))
+	a.Less(s.estimate("cold"), s.estimate("hot"))
+	// 计数在达到sampleSize后减半
+	for i := s.additions; i < s.sampleSize; i++ {
+		s.increment("other")
+	}
+	a.Less(s.estimate("hot"), uint8(5))
+}
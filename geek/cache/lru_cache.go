@@ -6,26 +6,18 @@ import (
 	"time"
 )
 
-type Cache interface {
-	Get(key string) (Value, bool)
-	Add(key string, value Value)
-	AddWithExpiration(key string, value Value, expirationTime time.Time)
-	Delete(key string) bool
-}
-
-type Value interface {
-	Len() int // return data size
-}
-
 // cache struct
 type lruCache struct {
 	lock      sync.Mutex
-	cacheMap  map[string]*list.Element      // map cache
-	expires   map[string]time.Time          // The expiration time of key
-	ll        *list.List                    // linked list
-	OnEvicted func(key string, value Value) // The callback function when a record is deleted
-	maxBytes  int64                         // The maximum memory allowed
-	nbytes    int64                         // The memory is currently in use
+	cacheMap  map[string]*list.Element // map cache
+	expires   map[string]time.Time     // The expiration time of key
+	index     expiryIndex              // The keys ordered by the expiration time
+	ll        *list.List               // linked list
+	OnEvicted EvictedFunc              // The callback function when a record is evicted
+	maxBytes  int64                    // The maximum memory allowed
+	nbytes    int64                    // The memory is currently in use
+	policy    MaxMemoryPolicy          // The eviction policy when maxBytes is exceeded
+	stopClean func()                   // stops the periodic clean
 }
 
 // 通过key可以在记录删除时，删除字典缓存中的映射
@@ -34,21 +26,21 @@ type entry struct {
 	value Value
 }
 
-func NewLRUCache(maxSize int64) *lruCache {
+func NewLRUCache(maxSize int64, opts ...CacheOptions) *lruCache {
+	return newLRUCache(maxSize, newOptions(opts...))
+}
+
+func newLRUCache(maxSize int64, o options) *lruCache {
 	answer := lruCache{
-		cacheMap: make(map[string]*list.Element),
-		expires:  make(map[string]time.Time),
-		nbytes:   0,
-		ll:       list.New(),
-		maxBytes: maxSize,
+		cacheMap:  make(map[string]*list.Element),
+		expires:   make(map[string]time.Time),
+		nbytes:    0,
+		ll:        list.New(),
+		maxBytes:  maxSize,
+		policy:    o.policy,
+		OnEvicted: o.onEvicted,
 	}
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			answer.periodicMemoryClean()
-		}
-	}()
+	answer.stopClean = startPeriodicClean(o.expiryInterval, answer.expire)
 	return &answer
 }
 
@@ -59,13 +51,10 @@ func (c *lruCache) Get(key string) (Value, bool) {
 	expirationTime, ok := c.expires[key]
 	if ok && expirationTime.Before(time.Now()) {
 		v := c.cacheMap[key].Value.(*entry)
-		c.nbytes -= int64(v.value.Len() + len(v.key))
-		c.ll.Remove(c.cacheMap[key])
-		delete(c.cacheMap, key)
-		delete(c.expires, key)
+		c.removeElement(c.cacheMap[key])
 		// rollback
 		if c.OnEvicted != nil {
-			c.OnEvicted(key, v.value)
+			c.OnEvicted(key, v.value, EXPIRED)
 		}
 		return nil, false
 	}
@@ -92,6 +81,7 @@ func (c *lruCache) AddWithExpiration(key string, value Value, expirationTime tim
 	defer c.lock.Unlock()
 	c.baseAdd(key, value)
 	c.expires[key] = expirationTime
+	c.index.add(key, expirationTime)
 	c.freeMemoryIfNeeded()
 }
 
@@ -99,9 +89,9 @@ func (c *lruCache) AddWithExpiration(key string, value Value, expirationTime tim
 func (c *lruCache) Delete(key string) bool {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	c.nbytes -= int64(len(key) + c.getValueSizeByKey(key))
-	delete(c.cacheMap, key)
-	delete(c.expires, key)
+	if e, ok := c.cacheMap[key]; ok {
+		c.removeElement(e)
+	}
 	return true
 }
 
@@ -121,41 +111,124 @@ func (c *lruCache) baseAdd(key string, value Value) {
 
 // lockless !!! free Memory when the memory is insufficient
 func (c *lruCache) freeMemoryIfNeeded() {
-	// 只有一种淘汰策略，lru
 	for c.nbytes > c.maxBytes {
-		v := c.ll.Front()
-		if v != nil {
-			c.ll.Remove(v)
-			kv := v.Value.(*entry)
-			delete(c.cacheMap, kv.key)
-			delete(c.expires, kv.key)
-			c.nbytes -= int64(len(kv.key) + kv.value.Len())
-			if c.OnEvicted != nil {
-				c.OnEvicted(kv.key, kv.value)
+		e := c.evictionCandidate()
+		if e == nil {
+			// volatile policies never evict the keys without expiration
+			return
+		}
+		kv := e.Value.(*entry)
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value, CAPACITY)
+		}
+	}
+}
+
+// lockless !!! pick the element to be evicted by the policy, nil if there is none
+func (c *lruCache) evictionCandidate() *list.Element {
+	switch c.policy {
+	case VOLATILE_LRU:
+		// the front of the list is the least recently used
+		for e := c.ll.Front(); e != nil; e = e.Next() {
+			if _, ok := c.expires[e.Value.(*entry).key]; ok {
+				return e
 			}
 		}
+	case VOLATILE_RANDOM:
+		// the iteration order of map is random
+		for key := range c.expires {
+			return c.cacheMap[key]
+		}
+	case ALLKEYS_RANDOM:
+		for _, e := range c.cacheMap {
+			return e
+		}
+	default:
+		return c.ll.Front()
 	}
+	return nil
 }
 
-// Scan and remove expired kv
-func (c *lruCache) periodicMemoryClean() {
+// lockless !!! remove the element from the linked list and the maps
+func (c *lruCache) removeElement(e *list.Element) {
+	kv := e.Value.(*entry)
+	c.ll.Remove(e)
+	delete(c.cacheMap, kv.key)
+	delete(c.expires, kv.key)
+	c.nbytes -= int64(len(kv.key) + kv.value.Len())
+}
+
+// expire removes the expired keys closest to their deadlines, the work of each tick is bounded by maxExpiredPerTick
+func (c *lruCache) expire() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	n := len(c.expires) / 10
-	for key := range c.expires {
-		// check for expiration
-		if c.expires[key].Before(time.Now()) {
-			c.nbytes -= int64(len(key) + c.getValueSizeByKey(key))
-			delete(c.expires, key)
-			delete(c.cacheMap, key)
+	c.index.popExpired(time.Now(), maxExpiredPerTick, c.expires, func(key string) {
+		e := c.cacheMap[key]
+		kv := e.Value.(*entry)
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value, EXPIRED)
 		}
-		n--
-		if n == 0 {
-			break
+	})
+	c.index.compact(c.expires)
+}
+
+// Peek is like Get, but doesn't change the order of eviction, and returns the expiration time too
+func (c *lruCache) Peek(key string) (Value, time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.cacheMap[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	expirationTime := c.expires[key]
+	if !expirationTime.IsZero() && expirationTime.Before(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	return e.Value.(*entry).value, expirationTime, true
+}
+
+// Range calls fn for each key not expired until it returns false, fn is called with the lock held
+func (c *lruCache) Range(fn func(key string, value Value, expirationTime time.Time) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	for key, e := range c.cacheMap {
+		expirationTime := c.expires[key]
+		if !expirationTime.IsZero() && expirationTime.Before(now) {
+			continue
+		}
+		if !fn(key, e.Value.(*entry).value, expirationTime) {
+			return
 		}
 	}
 }
 
+// Close stops the periodic clean and purges all keys without calling OnEvicted
+func (c *lruCache) Close() {
+	c.stopClean()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cacheMap = make(map[string]*list.Element)
+	c.expires = make(map[string]time.Time)
+	c.index = nil
+	c.ll.Init()
+	c.nbytes = 0
+}
+
+func (c *lruCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.cacheMap)
+}
+
+func (c *lruCache) Bytes() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.nbytes
+}
+
 func (c *lruCache) getValueSizeByKey(key string) int {
 	return c.cacheMap[key].Value.(*entry).value.Len()
 }
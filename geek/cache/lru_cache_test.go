@@ -87,6 +87,58 @@ func TestCache_FreeMemory2(t *testing.T) {
 	a.Equal(v3.(*testValue).b, "123456789")
 }
 
+// 检测volatile-lru只淘汰设置了过期时间的key
+func TestCache_VolatileLRU(t *testing.T) {
+	a := assert.New(t)
+	timeout := time.Now().Add(time.Minute)
+	cache := NewLRUCache(90, Policy(VOLATILE_LRU))
+	// session类数据, 没有过期时间
+	cache.Add("s0", &testValue{"12345678"})
+	cache.Add("s1", &testValue{"12345678"})
+	for i := 0; i < 7; i++ {
+		cache.AddWithExpiration(strconv.Itoa(i), &testValue{"123456789"}, timeout)
+	}
+	// key为0的最久未使用, 被淘汰
+	cache.AddWithExpiration("a", &testValue{"123456789"}, timeout)
+	_, f0 := cache.Get("0")
+	a.False(f0)
+	_, fs0 := cache.Get("s0")
+	a.True(fs0)
+	_, fs1 := cache.Get("s1")
+	a.True(fs1)
+	_, f1 := cache.Get("1")
+	a.True(f1)
+	// 没有过期时间的key不会被淘汰, 即使超出了内存限制
+	cache.Add("s2", &testValue{"123456789123456789123456789123456789123456789123456789123456789"})
+	for _, k := range []string{"s0", "s1", "s2"} {
+		_, f := cache.Get(k)
+		a.True(f)
+	}
+	_, fa := cache.Get("a")
+	a.False(fa)
+}
+
+// 检测random策略
+func TestCache_Random(t *testing.T) {
+	a := assert.New(t)
+	timeout := time.Now().Add(time.Minute)
+	cache := NewLRUCache(90, Policy(ALLKEYS_RANDOM))
+	for i := 0; i < 20; i++ {
+		cache.Add(strconv.Itoa(i), &testValue{"123456789"})
+	}
+	a.LessOrEqual(cache.nbytes, cache.maxBytes)
+	a.Equal(len(cache.cacheMap), cache.ll.Len())
+
+	cache = NewLRUCache(90, Policy(VOLATILE_RANDOM))
+	cache.Add("s0", &testValue{"12345678"})
+	for i := 0; i < 20; i++ {
+		cache.AddWithExpiration(strconv.Itoa(i), &testValue{"123456789"}, timeout)
+	}
+	a.LessOrEqual(cache.nbytes, cache.maxBytes)
+	_, fs0 := cache.Get("s0")
+	a.True(fs0)
+}
+
 // 测试超时
 func TestCache_AddWithExpiration(t *testing.T) {
 	a := assert.New(t)
@@ -117,6 +169,128 @@ func TestCache_Delete(t *testing.T) {
 	a.True(f4)
 }
 
+func TestCache_OnEvicted(t *testing.T) {
+	a := assert.New(t)
+	for _, algorithm := range []Algorithm{LRU, LFU, TINY_LFU} {
+		evicted := make(map[EvictionReason]int)
+		cache := New(algorithm, 400, OnEvicted(func(key string, value Value, reason EvictionReason) {
+			evicted[reason]++
+		}))
+		cache.AddWithExpiration("expired", &testValue{"1"}, time.Now().Add(-time.Second))
+		_, ok := cache.Get("expired")
+		a.False(ok)
+		a.Equal(1, evicted[EXPIRED])
+		// deleting is not evicting
+		cache.Add("deleted", &testValue{"1"})
+		cache.Delete("deleted")
+		a.Equal(0, evicted[CAPACITY])
+		for i := 0; i < 100; i++ {
+			cache.Add(strconv.Itoa(i), &testValue{"1234567890"})
+		}
+		a.True(evicted[CAPACITY] > 0, algorithm)
+		a.Equal(100-evicted[CAPACITY], cache.Len(), algorithm)
+		a.True(cache.Bytes() <= 400, algorithm)
+	}
+}
+
+func TestCache_PeekAndRange(t *testing.T) {
+	a := assert.New(t)
+	for _, cache := range []Cache{New(LRU, 400), New(LFU, 400), New(TINY_LFU, 400), New(LRU, 400, Shards(4))} {
+		expirationTime := time.Now().Add(time.Hour)
+		cache.Add("1", &testValue{"1"})
+		cache.AddWithExpiration("2", &testValue{"2"}, expirationTime)
+		cache.AddWithExpiration("expired", &testValue{"3"}, time.Now().Add(-time.Second))
+		v, exp, ok := cache.Peek("2")
+		a.True(ok)
+		a.Equal("2", v.(*testValue).b)
+		a.True(exp.Equal(expirationTime))
+		_, _, ok = cache.Peek("expired")
+		a.False(ok)
+		_, _, ok = cache.Peek("unknown")
+		a.False(ok)
+
+		keys := make(map[string]bool)
+		cache.Range(func(key string, value Value, expirationTime time.Time) bool {
+			keys[key] = true
+			return true
+		})
+		a.Equal(map[string]bool{"1": true, "2": true}, keys)
+		n := 0
+		cache.Range(func(key string, value Value, expirationTime time.Time) bool {
+			n++
+			return false
+		})
+		a.Equal(1, n)
+	}
+}
+
+func TestCache_Close(t *testing.T) {
+	a := assert.New(t)
+	for _, cache := range []Cache{New(LRU, 400), New(LFU, 400), New(TINY_LFU, 400), New(LRU, 400, Shards(4))} {
+		cache.Add("1", &testValue{"1"})
+		cache.AddWithExpiration("2", &testValue{"2"}, time.Now().Add(time.Hour))
+		cache.Close()
+		a.Equal(0, cache.Len())
+		a.Equal(int64(0), cache.Bytes())
+		_, ok := cache.Get("1")
+		a.False(ok)
+		// closing again is fine, and the cache is still usable
+		cache.Close()
+		cache.Add("3", &testValue{"3"})
+		_, ok = cache.Get("3")
+		a.True(ok)
+	}
+}
+
+func TestCache_ExpiryInterval(t *testing.T) {
+	a := assert.New(t)
+	for _, algorithm := range []Algorithm{LRU, LFU, TINY_LFU} {
+		var mu sync.Mutex
+		expired := make(map[string]bool)
+		cache := New(algorithm, 400, ExpiryInterval(10*time.Millisecond), Shards(2),
+			OnEvicted(func(key string, value Value, reason EvictionReason) {
+				mu.Lock()
+				defer mu.Unlock()
+				a.Equal(EXPIRED, reason)
+				expired[key] = true
+			}))
+		cache.Add("never", &testValue{"1"})
+		cache.AddWithExpiration("soon", &testValue{"2"}, time.Now().Add(20*time.Millisecond))
+		cache.AddWithExpiration("later", &testValue{"3"}, time.Now().Add(20*time.Millisecond))
+		// the stale entry of the old expiration is skipped
+		cache.AddWithExpiration("later", &testValue{"3"}, time.Now().Add(time.Hour))
+		// removed without Get
+		a.Eventually(func() bool { return cache.Len() == 2 }, time.Second, 5*time.Millisecond)
+		mu.Lock()
+		a.Equal(map[string]bool{"soon": true}, expired)
+		mu.Unlock()
+		cache.Close()
+	}
+}
+
+func TestExpiryIndex(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	var index expiryIndex
+	expires := make(map[string]time.Time)
+	for i, key := range []string{"3", "1", "2", "4"} {
+		expires[key] = now.Add(time.Duration(i-3) * time.Second)
+		index.add(key, expires[key])
+	}
+	delete(expires, "1")
+	// the earliest first, bounded by n, and the deleted one is skipped
+	var popped []string
+	index.popExpired(now, 2, expires, func(key string) {
+		popped = append(popped, key)
+	})
+	a.Equal([]string{"3"}, popped)
+	index.popExpired(now, 10, expires, func(key string) {
+		popped = append(popped, key)
+	})
+	a.Equal([]string{"3", "2", "4"}, popped)
+	a.Equal(0, index.Len())
+}
+
 // ByteView 只读的字节视图，用于缓存数据
 type testValue struct {
 	b string
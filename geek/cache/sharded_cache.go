@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"time"
+)
+
+// shardedCache hashes keys across segments which are locked independently,
+// so that concurrent calls on different keys don't serialize on a single lock
+type shardedCache struct {
+	shards []Cache
+}
+
+// NewShardedCache creates a cache of n lru segments, each of them has maxSize/n bytes
+func NewShardedCache(maxSize int64, n int, opts ...CacheOptions) *shardedCache {
+	return newShardedCache(LRU, maxSize, newOptions(append(opts, Shards(n))...))
+}
+
+func newShardedCache(algorithm Algorithm, maxSize int64, o options) *shardedCache {
+	n := o.shards
+	if n < 1 {
+		n = 1
+	}
+	answer := shardedCache{
+		shards: make([]Cache, n),
+	}
+	for i := range answer.shards {
+		answer.shards[i] = newCache(algorithm, maxSize/int64(n), o)
+	}
+	return &answer
+}
+
+func (c *shardedCache) Get(key string) (Value, bool) {
+	return c.shard(key).Get(key)
+}
+
+func (c *shardedCache) Add(key string, value Value) {
+	c.shard(key).Add(key, value)
+}
+
+func (c *shardedCache) AddWithExpiration(key string, value Value, expirationTime time.Time) {
+	c.shard(key).AddWithExpiration(key, value, expirationTime)
+}
+
+func (c *shardedCache) Delete(key string) bool {
+	return c.shard(key).Delete(key)
+}
+
+func (c *shardedCache) Peek(key string) (Value, time.Time, bool) {
+	return c.shard(key).Peek(key)
+}
+
+func (c *shardedCache) Range(fn func(key string, value Value, expirationTime time.Time) bool) {
+	for _, shard := range c.shards {
+		stopped := false
+		shard.Range(func(key string, value Value, expirationTime time.Time) bool {
+			stopped = !fn(key, value, expirationTime)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+func (c *shardedCache) Close() {
+	for _, shard := range c.shards {
+		shard.Close()
+	}
+}
+
+func (c *shardedCache) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+func (c *shardedCache) Bytes() int64 {
+	var n int64
+	for _, shard := range c.shards {
+		n += shard.Bytes()
+	}
+	return n
+}
+
+// shard picks the segment of key by fnv-1a
+func (c *shardedCache) shard(key string) Cache {
+	var h uint32 = 2166136261
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 检测并发情况下是否会出现问题
+func TestShardedCache_GetAndAdd(t *testing.T) {
+	a := assert.New(t)
+	var wg sync.WaitGroup
+	cache := NewShardedCache(1000000000, 16)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				cache.Add(strconv.Itoa(g*10000+i), &testValue{"东神牛逼"})
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < 80000; i++ {
+		v, ok := cache.Get(strconv.Itoa(i))
+		a.True(ok)
+		a.Equal("东神牛逼", v.(*testValue).b)
+	}
+	cache.Delete("1")
+	_, ok := cache.Get("1")
+	a.False(ok)
+}
+
+// 检测每个分片的内存限制
+func TestShardedCache_FreeMemory(t *testing.T) {
+	a := assert.New(t)
+	cache := NewShardedCache(1000, 4)
+	for i := 0; i < 1000; i++ {
+		cache.Add(strconv.Itoa(i), &testValue{"123456789"})
+	}
+	var total int64
+	for _, shard := range cache.shards {
+		lru := shard.(*lruCache)
+		a.Equal(int64(250), lru.maxBytes)
+		a.LessOrEqual(lru.nbytes, lru.maxBytes)
+		total += lru.nbytes
+	}
+	a.LessOrEqual(total, int64(1000))
+	// 最近添加的key未被淘汰
+	_, ok := cache.Get("999")
+	a.True(ok)
+}
+
+func TestNew_Shards(t *testing.T) {
+	a := assert.New(t)
+	c := New(TINY_LFU, 1000, Shards(8))
+	sharded, ok := c.(*shardedCache)
+	a.True(ok)
+	a.Equal(8, len(sharded.shards))
+	_, ok = sharded.shards[0].(*tinyLFUCache)
+	a.True(ok)
+	_, ok = New(LRU, 1000, Shards(1)).(*lruCache)
+	a.True(ok)
+}
+
+func benchmarkParallel(b *testing.B, cache Cache) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		cache.Add(keys[i], &testValue{"123456789"})
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			// 读多写少
+			if i%10 == 0 {
+				cache.Add(key, &testValue{"123456789"})
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkLRUCache_Parallel(b *testing.B) {
+	benchmarkParallel(b, NewLRUCache(1<<30))
+}
+
+func BenchmarkShardedCache_Parallel(b *testing.B) {
+	benchmarkParallel(b, NewShardedCache(1<<30, 32))
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"hash/fnv"
+)
+
+const (
+	sketchDepth    = 4  // number of rows, each row uses a different hash
+	sketchMaxCount = 15 // counters are saturated at 15 like a 4-bit counter
+)
+
+// cmSketch is a count-min sketch that estimates the access frequency of keys.
+// All counters are halved once the number of increments reaches sampleSize,
+// so that the old popularity fades out.
+type cmSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint32
+	additions  int
+	sampleSize int
+}
+
+// newCMSketch creates a sketch with at least width counters per row
+func newCMSketch(width int) *cmSketch {
+	n := 1
+	for n < width {
+		n <<= 1
+	}
+	s := &cmSketch{
+		mask:       uint32(n - 1),
+		sampleSize: 10 * n,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, n)
+	}
+	return s
+}
+
+// increment the frequency of key
+func (s *cmSketch) increment(key string) {
+	h1, h2 := sketchHash(key)
+	for i := range s.rows {
+		idx := (h1 + uint32(i)*h2) & s.mask
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// estimate the frequency of key, the minimum of all rows
+func (s *cmSketch) estimate(key string) uint8 {
+	h1, h2 := sketchHash(key)
+	min := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if v := s.rows[i][(h1+uint32(i)*h2)&s.mask]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// reset halves all counters
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// double hashing, the hash of row i is h1 + i*h2
+func sketchHash(key string) (uint32, uint32) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	return uint32(sum), uint32(sum>>32) | 1
+}
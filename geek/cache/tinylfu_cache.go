@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	windowPercent    = 1  // percent of maxBytes used by the window lru
+	protectedPercent = 80 // percent of the main space used by the protected segment
+	// the sketch is sized by the estimated number of keys, assuming 64 bytes per key-value
+	sketchBytesPerKey = 64
+	minSketchWidth    = 64
+	maxSketchWidth    = 1 << 22
+)
+
+// segments of W-TinyLFU
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+// tinyLFUCache is a W-TinyLFU cache.
+// New keys enter a small window lru, the keys evicted from the window are candidates
+// to the main space, which is a segmented lru of probation and protected.
+// A candidate is admitted only if its frequency estimated by a count-min sketch is higher
+// than the frequency of the victim of the main space, so one-off scans can't flush hot keys.
+type tinyLFUCache struct {
+	lock         sync.Mutex
+	cacheMap     map[string]*list.Element // map cache
+	expires      map[string]time.Time     // The expiration time of key
+	index        expiryIndex              // The keys ordered by the expiration time
+	segments     [3]*list.List            // window, probation and protected, the front is the least recently used
+	bytes        [3]int64                 // The memory is currently in use of each segment
+	sketch       *cmSketch                // The frequency of keys
+	OnEvicted    EvictedFunc              // The callback function when a record is evicted
+	maxBytes     int64                    // The maximum memory allowed
+	maxWindow    int64                    // The maximum memory of the window
+	maxProtected int64                    // The maximum memory of the protected segment
+	stopClean    func()                   // stops the periodic clean
+}
+
+type tinyLFUEntry struct {
+	key     string
+	value   Value
+	segment int
+}
+
+func NewTinyLFUCache(maxSize int64, opts ...CacheOptions) *tinyLFUCache {
+	return newTinyLFUCache(maxSize, newOptions(opts...))
+}
+
+func newTinyLFUCache(maxSize int64, o options) *tinyLFUCache {
+	if o.policy != ALLKEYS_LRU {
+		panic("the memory policy only works with LRU")
+	}
+	width := int(maxSize / sketchBytesPerKey)
+	if width < minSketchWidth {
+		width = minSketchWidth
+	} else if width > maxSketchWidth {
+		width = maxSketchWidth
+	}
+	maxWindow := maxSize * windowPercent / 100
+	answer := tinyLFUCache{
+		cacheMap:     make(map[string]*list.Element),
+		expires:      make(map[string]time.Time),
+		segments:     [3]*list.List{list.New(), list.New(), list.New()},
+		sketch:       newCMSketch(width),
+		maxBytes:     maxSize,
+		maxWindow:    maxWindow,
+		maxProtected: (maxSize - maxWindow) * protectedPercent / 100,
+		OnEvicted:    o.onEvicted,
+	}
+	answer.stopClean = startPeriodicClean(o.expiryInterval, answer.expire)
+	return &answer
+}
+
+func (c *tinyLFUCache) Get(key string) (Value, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sketch.increment(key)
+	e, ok := c.cacheMap[key]
+	if !ok {
+		return nil, false
+	}
+	kv := e.Value.(*tinyLFUEntry)
+	// check for expiration
+	if expirationTime, ok := c.expires[key]; ok && expirationTime.Before(time.Now()) {
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(key, kv.value, EXPIRED)
+		}
+		return nil, false
+	}
+	c.touch(e)
+	return kv.value, true
+}
+
+// add a key-value
+func (c *tinyLFUCache) Add(key string, value Value) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.baseAdd(key, value)
+	delete(c.expires, key)
+	c.freeMemoryIfNeeded()
+}
+
+// add a key-value whth expiration
+func (c *tinyLFUCache) AddWithExpiration(key string, value Value, expirationTime time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.baseAdd(key, value)
+	c.expires[key] = expirationTime
+	c.index.add(key, expirationTime)
+	c.freeMemoryIfNeeded()
+}
+
+// delete a key-value by key
+func (c *tinyLFUCache) Delete(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.cacheMap[key]; ok {
+		c.removeElement(e)
+	}
+	return true
+}
+
+func (c *tinyLFUCache) baseAdd(key string, value Value) {
+	// a write is an access too
+	c.sketch.increment(key)
+	// Check whether the key already exists
+	if e, ok := c.cacheMap[key]; ok {
+		kv := e.Value.(*tinyLFUEntry)
+		c.bytes[kv.segment] += int64(value.Len() - kv.value.Len())
+		// update value
+		kv.value = value
+		c.touch(e)
+		return
+	}
+	kv := &tinyLFUEntry{key: key, value: value, segment: segWindow}
+	c.bytes[segWindow] += int64(len(key) + value.Len())
+	c.cacheMap[key] = c.segments[segWindow].PushBack(kv)
+}
+
+// lockless !!! record an access of the element
+func (c *tinyLFUCache) touch(e *list.Element) {
+	kv := e.Value.(*tinyLFUEntry)
+	switch kv.segment {
+	case segProbation:
+		// promote to protected, and demote the least recently used of protected if it's full
+		c.move(e, segProtected)
+		for c.bytes[segProtected] > c.maxProtected {
+			c.move(c.segments[segProtected].Front(), segProbation)
+		}
+	default:
+		c.segments[kv.segment].MoveToBack(e)
+	}
+}
+
+// lockless !!! move the element to the back of the segment, return the new element
+func (c *tinyLFUCache) move(e *list.Element, segment int) *list.Element {
+	kv := e.Value.(*tinyLFUEntry)
+	size := int64(len(kv.key) + kv.value.Len())
+	c.segments[kv.segment].Remove(e)
+	c.bytes[kv.segment] -= size
+	kv.segment = segment
+	c.bytes[segment] += size
+	c.cacheMap[kv.key] = c.segments[segment].PushBack(kv)
+	return c.cacheMap[kv.key]
+}
+
+// lockless !!! free Memory when the memory is insufficient
+func (c *tinyLFUCache) freeMemoryIfNeeded() {
+	// the overflow of the window become candidates of the main space
+	for c.bytes[segWindow] > c.maxWindow {
+		candidate := c.move(c.segments[segWindow].Front(), segProbation)
+		c.admit(candidate)
+	}
+	// the values of the main space may be updated to larger ones
+	c.admit(nil)
+}
+
+// lockless !!! evict keys of the main space until it fits,
+// the candidate competes with the victims by the frequency
+func (c *tinyLFUCache) admit(candidate *list.Element) {
+	for c.bytes[segProbation]+c.bytes[segProtected] > c.maxBytes-c.maxWindow {
+		victim := c.segments[segProbation].Front()
+		if victim == nil {
+			victim = c.segments[segProtected].Front()
+		}
+		if candidate != nil && victim != candidate &&
+			c.sketch.estimate(candidate.Value.(*tinyLFUEntry).key) <= c.sketch.estimate(victim.Value.(*tinyLFUEntry).key) {
+			victim = candidate
+		}
+		if victim == candidate {
+			candidate = nil
+		}
+		kv := victim.Value.(*tinyLFUEntry)
+		c.removeElement(victim)
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value, CAPACITY)
+		}
+	}
+}
+
+// lockless !!! remove the element from its segment and the maps
+func (c *tinyLFUCache) removeElement(e *list.Element) {
+	kv := e.Value.(*tinyLFUEntry)
+	c.segments[kv.segment].Remove(e)
+	c.bytes[kv.segment] -= int64(len(kv.key) + kv.value.Len())
+	delete(c.cacheMap, kv.key)
+	delete(c.expires, kv.key)
+}
+
+// expire removes the expired keys closest to their deadlines, the work of each tick is bounded by maxExpiredPerTick
+func (c *tinyLFUCache) expire() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.index.popExpired(time.Now(), maxExpiredPerTick, c.expires, func(key string) {
+		e := c.cacheMap[key]
+		kv := e.Value.(*tinyLFUEntry)
+		c.removeElement(e)
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value, EXPIRED)
+		}
+	})
+	c.index.compact(c.expires)
+}
+
+// Peek is like Get, but doesn't change the order of eviction, and returns the expiration time too
+func (c *tinyLFUCache) Peek(key string) (Value, time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.cacheMap[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	expirationTime := c.expires[key]
+	if !expirationTime.IsZero() && expirationTime.Before(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	return e.Value.(*tinyLFUEntry).value, expirationTime, true
+}
+
+// Range calls fn for each key not expired until it returns false, fn is called with the lock held
+func (c *tinyLFUCache) Range(fn func(key string, value Value, expirationTime time.Time) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	for key, e := range c.cacheMap {
+		expirationTime := c.expires[key]
+		if !expirationTime.IsZero() && expirationTime.Before(now) {
+			continue
+		}
+		if !fn(key, e.Value.(*tinyLFUEntry).value, expirationTime) {
+			return
+		}
+	}
+}
+
+// Close stops the periodic clean and purges all keys and frequencies without calling OnEvicted
+func (c *tinyLFUCache) Close() {
+	c.stopClean()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cacheMap = make(map[string]*list.Element)
+	c.expires = make(map[string]time.Time)
+	c.index = nil
+	for i := range c.segments {
+		c.segments[i].Init()
+		c.bytes[i] = 0
+	}
+	c.sketch = newCMSketch(len(c.sketch.rows[0]))
+}
+
+func (c *tinyLFUCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.cacheMap)
+}
+
+func (c *tinyLFUCache) Bytes() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.bytes[segWindow] + c.bytes[segProbation] + c.bytes[segProtected]
+}
@@ -2,88 +2,385 @@ package geek
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/Makonike/geek-cache/geek/pb"
-	registry "github.com/Makonike/geek-cache/geek/registry"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPoolSize = 1
+	defaultTimeout  = 3 * time.Second
 )
 
 type Client struct {
-	addr        string // name of remote server, e.g. ip:port
-	serviceName string // name of service, e.g. geek-cache
+	addr      string             // name of remote server, e.g. ip:port
+	conns     []*grpc.ClientConn // long-lived connections to the remote server
+	next      uint32             // the next connection to use, round robin
+	poolSize  int                // number of connections
+	timeout   time.Duration      // timeout of each call
+	keepalive *keepalive.ClientParameters
+	creds     credentials.TransportCredentials // plaintext by default
+	latency   rpcHistograms                    // latency of the calls by method
+	tracer    trace.Tracer
+	signer    Signer // the calls are not signed if nil
+}
+
+type ClientOptions func(*Client)
+
+// NewClient creates a new client, the connections are created once here
+// and reused by all calls until Close.
+// serviceName is ignored since the client dials addr directly, it's kept for compatibility
+func NewClient(addr, serviceName string, opts ...ClientOptions) (*Client, error) {
+	c := Client{
+		addr:     addr,
+		poolSize: defaultPoolSize,
+		timeout:  defaultTimeout,
+		creds:    insecure.NewCredentials(),
+		latency:  newRPCHistograms(),
+		tracer:   newTracer(nil),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	interceptors := []grpc.UnaryClientInterceptor{tracingUnaryClientInterceptor(c.tracer)}
+	var streamInterceptors []grpc.StreamClientInterceptor
+	if c.signer != nil {
+		interceptors = append(interceptors, authUnaryClientInterceptor(c.signer))
+		streamInterceptors = append(streamInterceptors, authStreamClientInterceptor(c.signer))
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(c.creds),
+		grpc.WithChainUnaryInterceptor(interceptors...),
+		grpc.WithChainStreamInterceptor(streamInterceptors...),
+	}
+	if c.keepalive != nil {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(*c.keepalive))
+	}
+	for i := 0; i < c.poolSize; i++ {
+		// no blocking, the connection is established in background and reconnected when broken
+		conn, err := grpc.Dial(addr, dialOpts...)
+		if err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("could not dial peer %s: %v", addr, err)
+		}
+		c.conns = append(c.conns, conn)
+	}
+	return &c, nil
+}
+
+// ClientPoolSize sets the number of connections to the remote server, 1 by default
+func ClientPoolSize(n int) ClientOptions {
+	return func(c *Client) {
+		if n > 0 {
+			c.poolSize = n
+		}
+	}
+}
+
+// ClientTimeout sets the timeout of each call, 3s by default,
+// the deadline of the caller's context is used if it's earlier
+func ClientTimeout(timeout time.Duration) ClientOptions {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// ClientKeepalive sets the keepalive of the connections,
+// the server should permit it by ServerKeepalivePolicy
+func ClientKeepalive(kp keepalive.ClientParameters) ClientOptions {
+	return func(c *Client) {
+		c.keepalive = &kp
+	}
+}
+
+// ClientTLS dials the remote server over TLS, e.g. the config of TLSFiles.ClientConfig,
+// the server should serve with ServerTLS
+func ClientTLS(config *tls.Config) ClientOptions {
+	return func(c *Client) {
+		c.creds = credentials.NewTLS(config)
+	}
+}
+
+// ClientAuth signs the calls by signer, e.g. TokenAuth or HMACAuth, the server should accept them by ServerAuth
+func ClientAuth(signer Signer) ClientOptions {
+	return func(c *Client) {
+		c.signer = signer
+	}
 }
 
-// NewClient creates a new client
-func NewClient(addr, serviceName string) *Client {
-	return &Client{
-		addr:        addr,
-		serviceName: serviceName,
+// ClientTracerProvider sets where the spans of the calls are created,
+// the trace context is sent to the server so that one trace spans nodes. The spans are not recorded by default
+func ClientTracerProvider(tp trace.TracerProvider) ClientOptions {
+	return func(c *Client) {
+		c.tracer = newTracer(tp)
 	}
 }
 
+// Close closes all connections of the client
+func (c *Client) Close() error {
+	var err error
+	for _, conn := range c.conns {
+		if e := conn.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Stats returns the latency of the calls to the remote server
+func (c *Client) Stats() RPCStats {
+	return c.latency.stats()
+}
+
+// grpcClient picks a connection of the pool
+func (c *Client) grpcClient() pb.GroupCacheClient {
+	n := atomic.AddUint32(&c.next, 1)
+	return pb.NewGroupCacheClient(c.conns[n%uint32(len(c.conns))])
+}
+
 // Get send the url for getting specific group and key,
 // and return the result
-func (c *Client) Get(group, key string) ([]byte, error) {
-	cli, err := clientv3.New(*registry.GlobalClientConfig)
+func (c *Client) Get(ctx context.Context, group, key string) ([]byte, error) {
+	defer c.latency.observe("get", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.grpcClient().Get(ctx, &pb.Request{
+		Group: group,
+		Key:   key,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, fmt.Errorf("could not get %s-%s from peer %s", group, key, c.addr)
 	}
-	defer cli.Close()
+	return resp.GetValue(), nil
+}
+
+// Delete send the url for getting specific group and key,
+// and return the result
+func (c *Client) Delete(ctx context.Context, group string, key string) (bool, error) {
+	defer c.latency.observe("delete", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
-	conn, err := registry.EtcdDial(cli, c.serviceName, c.addr)
+	resp, err := c.grpcClient().Delete(ctx, &pb.Request{
+		Group: group,
+		Key:   key,
+	})
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("could not delete %s-%s from peer %s", group, key, c.addr)
 	}
-	defer conn.Close()
+	return resp.GetValue(), newInvalidationError(key, resp.GetInvalidationErrors())
+}
 
-	grpcCLient := pb.NewGroupCacheClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// newInvalidationError rebuilds the *InvalidationError sent by the peer, nil if all peers acknowledged
+func newInvalidationError(key string, failed map[string]string) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	e := &InvalidationError{Key: key, Failed: make(map[string]error, len(failed))}
+	for addr, msg := range failed {
+		e.Failed[addr] = errors.New(msg)
+	}
+	return e
+}
+
+// remoteError maps the error of a key sent by the peer back to the sentinel errors,
+// so that the callers get the same errors whether the key is handled by self or by the peer
+func remoteError(msg string) error {
+	for _, err := range []error{ErrNotFound, ErrGroupClosed, context.Canceled, context.DeadlineExceeded} {
+		if msg == err.Error() {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+// Set send the key-value to the peer which owns the key,
+// and return the result
+func (c *Client) Set(ctx context.Context, group, key string, value []byte, ttl time.Duration) (bool, error) {
+	defer c.latency.observe("set", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	resp, err := grpcCLient.Get(ctx, &pb.Request{
+	resp, err := c.grpcClient().Set(ctx, &pb.SetRequest{
 		Group: group,
 		Key:   key,
+		Value: value,
+		Ttl:   ttl.Milliseconds(),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("could not get %s-%s from peer %s", group, key, c.addr)
+		return false, fmt.Errorf("could not set %s-%s to peer %s", group, key, c.addr)
+	}
+	return resp.GetValue(), newInvalidationError(key, resp.GetInvalidationErrors())
+}
+
+// SetReplica send the key-value to the peer which holds a replica of the key,
+// the peer stores it by itself, and return the result
+func (c *Client) SetReplica(ctx context.Context, group, key string, value []byte, ttl time.Duration) (bool, error) {
+	defer c.latency.observe("setreplica", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.grpcClient().Set(ctx, &pb.SetRequest{
+		Group:   group,
+		Key:     key,
+		Value:   value,
+		Ttl:     ttl.Milliseconds(),
+		Replica: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not set replica %s-%s to peer %s", group, key, c.addr)
 	}
 	return resp.GetValue(), nil
 }
 
-// Delete send the url for getting specific group and key,
-// and return the result
-func (c *Client) Delete(group string, key string) (bool, error) {
-	cli, err := clientv3.New(*registry.GlobalClientConfig)
+// MGet send the keys owned by the peer in one request,
+// and return the results in the order of keys
+func (c *Client) MGet(ctx context.Context, group string, keys []string) ([]GetResult, error) {
+	defer c.latency.observe("mget", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
+	resp, err := c.grpcClient().MGet(ctx, &pb.BatchRequest{
+		Group: group,
+		Keys:  keys,
+	})
 	if err != nil {
-		log.Fatal(err)
-		return false, err
+		return nil, fmt.Errorf("could not get %d keys of %s from peer %s", len(keys), group, c.addr)
 	}
-	defer cli.Close()
+	results := make([]GetResult, len(resp.GetResults()))
+	for i, r := range resp.GetResults() {
+		results[i].Key = r.GetKey()
+		if r.GetNotFound() {
+			results[i].Err = ErrNotFound
+			continue
+		}
+		if r.GetError() != "" {
+			results[i].Err = remoteError(r.GetError())
+			continue
+		}
+		results[i].Value = ByteView{b: r.GetValue()}
+	}
+	return results, nil
+}
+
+// MDelete send the keys owned by the peer in one request,
+// and return the results in the order of keys
+func (c *Client) MDelete(ctx context.Context, group string, keys []string) ([]DeleteResult, error) {
+	defer c.latency.observe("mdelete", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
-	conn, err := registry.EtcdDial(cli, c.serviceName, c.addr)
+	resp, err := c.grpcClient().MDelete(ctx, &pb.BatchRequest{
+		Group: group,
+		Keys:  keys,
+	})
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("could not delete %d keys of %s from peer %s", len(keys), group, c.addr)
 	}
-	defer conn.Close()
+	results := make([]DeleteResult, len(resp.GetResults()))
+	for i, r := range resp.GetResults() {
+		results[i].Key = r.GetKey()
+		results[i].Success = r.GetValue()
+		if r.GetError() != "" {
+			results[i].Err = remoteError(r.GetError())
+			continue
+		}
+		results[i].Err = newInvalidationError(r.GetKey(), r.GetInvalidationErrors())
+	}
+	return results, nil
+}
 
-	grpcCLient := pb.NewGroupCacheClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// Invalidate asks the peer to drop the key from its local caches,
+// and return nil if the peer acknowledged
+func (c *Client) Invalidate(ctx context.Context, group string, key string) error {
+	defer c.latency.observe("invalidate", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	resp, err := grpcCLient.Delete(ctx, &pb.Request{
+	_, err := c.grpcClient().Invalidate(ctx, &pb.Request{
 		Group: group,
 		Key:   key,
 	})
 	if err != nil {
-		return false, fmt.Errorf("could not delete %s-%s from peer %s", group, key, c.addr)
+		return fmt.Errorf("could not invalidate %s-%s on peer %s: %v", group, key, c.addr, err)
 	}
-	return resp.GetValue(), nil
+	return nil
+}
+
+// Handoff streams the entries which the remote server owns now,
+// and return the number of entries it kept. The stream is bounded by the timeout of the client,
+// so the entries should be sent by batches, e.g. of handoffBatchSize
+func (c *Client) Handoff(ctx context.Context, group string, entries []HandoffEntry) (int, error) {
+	defer c.latency.observe("handoff", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	stream, err := c.grpcClient().Handoff(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not hand off %d keys of %s to peer %s: %v", len(entries), group, c.addr, err)
+	}
+	for _, e := range entries {
+		var expire int64
+		if !e.ExpirationTime.IsZero() {
+			expire = e.ExpirationTime.UnixMilli()
+		}
+		if err := stream.Send(&pb.HandoffEntry{
+			Group:  group,
+			Key:    e.Key,
+			Value:  e.Value.ByteSLice(),
+			Expire: expire,
+		}); err != nil {
+			// the error is returned by CloseAndRecv
+			break
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, fmt.Errorf("could not hand off %d keys of %s to peer %s: %v", len(entries), group, c.addr, err)
+	}
+	return int(resp.GetAccepted()), nil
+}
+
+// Peek send the url for the value cached by the peer without calling its Getter,
+// and return the result
+func (c *Client) Peek(ctx context.Context, group string, key string) ([]byte, time.Time, error) {
+	defer c.latency.observe("peek", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.grpcClient().Peek(ctx, &pb.Request{
+		Group: group,
+		Key:   key,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("could not peek %s-%s from peer %s", group, key, c.addr)
+	}
+	var expirationTime time.Time
+	if resp.GetExpire() > 0 {
+		expirationTime = time.UnixMilli(resp.GetExpire())
+	}
+	return resp.GetValue(), expirationTime, nil
 }
 
 // resure implemented
 var _ PeerGetter = (*Client)(nil)
+var _ HandoffPeer = (*Client)(nil)
+var _ ReplicaPeer = (*Client)(nil)
@@ -0,0 +1,139 @@
+package geek
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Makonike/geek-cache/geek/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDBGroup creates a private group, the Getter of which returns "db" + key for all keys
+func newDBGroup(name string, opts ...GroupOptions) *Group {
+	getter := GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte("db" + key), true, time.Time{}
+	})
+	return NewGroup(name, 2<<10, getter, append([]GroupOptions{PrivateForTesting()}, opts...)...)
+}
+
+// serveGroup serves g alone on a random port of loopback with an in-memory registry,
+// the server is stopped when the test ends
+func serveGroup(t *testing.T, g *Group, opts ...ServerOptions) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]ServerOptions{ServerDiscovery(registry.NewMemoryDiscovery()),
+		ServerGroupsForTesting(func(string) *Group { return g })}, opts...)
+	s, err := NewServer(l.Addr().String(), opts...)
+	if err != nil {
+		_ = l.Close()
+		t.Fatal(err)
+	}
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = s.Stop(context.Background())
+	})
+	return s, l.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	a := assert.New(t)
+	loads := make(map[string]int)
+	g := NewGroup("client", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			if v, ok := db[key]; ok {
+				loads[key] += 1
+				return []byte(v), true, time.Time{}
+			}
+			return nil, false, time.Time{}
+		}))
+	_, addr := serveGroup(t, g)
+
+	client, err := NewClient(addr, defaultServiceName, ClientPoolSize(2), ClientTimeout(time.Second))
+	a.Nil(err)
+	defer client.Close()
+	a.Equal(2, len(client.conns))
+
+	ctx := context.Background()
+	// the connections are reused by all calls
+	for i := 0; i < 4; i++ {
+		v, err := client.Get(ctx, "client", "Tom")
+		a.Nil(err)
+		a.Equal("630", string(v))
+	}
+	a.Equal(1, loads["Tom"])
+	_, err = client.Get(ctx, "client", "unknown")
+	a.ErrorIs(err, ErrNotFound)
+
+	s, err := client.Set(ctx, "client", "Bob", []byte("599"), time.Minute)
+	a.True(s)
+	a.Nil(err)
+	s, err = client.SetReplica(ctx, "client", "Lily", []byte("688"), time.Minute)
+	a.True(s)
+	a.Nil(err)
+	// the replicas are measured apart from the sets
+	a.Equal(int64(1), client.Stats()["set"].Count)
+	a.Equal(int64(1), client.Stats()["setreplica"].Count)
+	results, err := client.MGet(ctx, "client", []string{"Bob", "Jack", "unknown"})
+	a.Nil(err)
+	a.Equal("599", results[0].Value.String())
+	a.Equal("742", results[1].Value.String())
+	a.ErrorIs(results[2].Err, ErrNotFound)
+
+	s, err = client.Delete(ctx, "client", "Tom")
+	a.True(s)
+	a.Nil(err)
+	_, _ = client.Get(ctx, "client", "Tom")
+	a.Equal(2, loads["Tom"])
+
+	// invalidate drops the key from the caches of the peer
+	a.Nil(client.Invalidate(ctx, "client", "Jack"))
+	_, _ = client.Get(ctx, "client", "Jack")
+	a.Equal(2, loads["Jack"])
+	// nothing to drop if the peer doesn't have the group
+	a.Nil(client.Invalidate(ctx, "unknown", "Jack"))
+
+	a.Nil(client.Close())
+	_, err = client.Get(ctx, "client", "Tom")
+	a.NotNil(err)
+}
+
+func TestClient_RemoteErrors(t *testing.T) {
+	a := assert.New(t)
+	g := newDBGroup("client-errors")
+	g.RegisterPeers(&fakePicker{peers: map[byte]*fakePeer{'c': {name: "C", fail: true}}})
+	_, addr := serveGroup(t, g)
+	client, err := NewClient(addr, defaultServiceName)
+	a.Nil(err)
+	defer client.Close()
+	ctx := context.Background()
+
+	// the peer failed to invalidate the keys, they are deleted anyway
+	s, err := client.Delete(ctx, "client-errors", "s1")
+	a.True(s)
+	invalidationErr, ok := err.(*InvalidationError)
+	a.True(ok)
+	a.NotNil(invalidationErr.Failed["C"])
+	deleted, err := client.MDelete(ctx, "client-errors", []string{"s1", "s2"})
+	a.Nil(err)
+	for _, r := range deleted {
+		invalidationErr, ok := r.Err.(*InvalidationError)
+		a.True(ok)
+		a.Equal(r.Key, invalidationErr.Key)
+		a.NotNil(invalidationErr.Failed["C"])
+	}
+
+	// the sentinel errors are the same as the ones returned by the group
+	a.Nil(g.Close())
+	results, err := client.MGet(ctx, "client-errors", []string{"s1"})
+	a.Nil(err)
+	a.Equal(ErrGroupClosed, results[0].Err)
+	deleted, err = client.MDelete(ctx, "client-errors", []string{"s1"})
+	a.Nil(err)
+	a.Equal(ErrGroupClosed, deleted[0].Err)
+}
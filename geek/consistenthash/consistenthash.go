@@ -82,6 +82,44 @@ func (m *Map) Get(key string) string {
 	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
 
+// GetN gets the n distinct reality nodes closest to the key clockwise, the first one is the result of Get.
+// All the nodes are returned if there are less than n
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	// 顺时针跳过同一真实节点的其他虚拟节点
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Clone returns a copy of the hash, it isn't affected by the changes of m
+func (m *Map) Clone() *Map {
+	c := Map{
+		hash:     m.hash,
+		replicas: m.replicas,
+		keys:     make([]int, len(m.keys)),
+		hashMap:  make(map[int]string, len(m.hashMap)),
+	}
+	copy(c.keys, m.keys)
+	for k, v := range m.hashMap {
+		c.hashMap[k] = v
+	}
+	return &c
+}
+
 // Remove removes some node from the hash.
 func (m *Map) Remove(key string) {
 	for i := 0; i < m.replicas; i++ {
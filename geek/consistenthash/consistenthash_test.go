@@ -2,6 +2,7 @@ package consistenthash
 
 import (
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +43,47 @@ func TestMap_Get(t *testing.T) {
 		}
 	}
 }
+
+func TestMap_Clone(t *testing.T) {
+	hash := New(Replicas(3), HashFunc(func(data []byte) uint32 {
+		i, _ := strconv.Atoi(string(data))
+		return uint32(i)
+	}))
+	hash.Add("6", "4", "2")
+	clone := hash.Clone()
+	// the clone is not changed with the original
+	hash.Add("8")
+	if clone.Get("27") != "2" {
+		t.Errorf("clone.Get(27) expeted 2, but %s", clone.Get("27"))
+	}
+	if hash.Get("27") != "8" {
+		t.Errorf("hash.Get(27) expeted 8, but %s", hash.Get("27"))
+	}
+}
+
+func TestMap_GetN(t *testing.T) {
+	hash := New(Replicas(3), HashFunc(func(data []byte) uint32 {
+		i, _ := strconv.Atoi(string(data))
+		return uint32(i)
+	}))
+	// add vir-node 06, 16, 26, 04, 14, 24, 02, 12, 22
+	hash.Add("6", "4", "2")
+	testCases := map[string][]string{
+		"11": {"2", "4"},      // 12 - 2, 14 - 4
+		"23": {"4", "6"},      // 24 - 4, 26 - 6
+		"27": {"2", "4"},      // 02 - 2, 04 - 4
+		"5":  {"6", "2", "4"}, // all the nodes
+	}
+	for k, v := range testCases {
+		got := hash.GetN(k, len(v))
+		if strings.Join(got, ",") != strings.Join(v, ",") {
+			t.Errorf("hash.GetN(%s) expeted %v, but %v", k, v, got)
+		}
+		if got[0] != hash.Get(k) {
+			t.Errorf("hash.GetN(%s)[0] expeted %s, but %s", k, hash.Get(k), got[0])
+		}
+	}
+	if got := hash.GetN("5", 5); len(got) != 3 {
+		t.Errorf("hash.GetN(5, 5) expeted 3 nodes, but %v", got)
+	}
+}
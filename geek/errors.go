@@ -0,0 +1,35 @@
+package geek
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned when the Getter reports the key as not found,
+// or the key is in the negative cache
+var ErrNotFound = errors.New("data not found")
+
+// ErrGroupClosed is returned by the calls to a group after Close, e.g. it's destroyed or replaced by NewGroup
+var ErrGroupClosed = errors.New("group closed")
+
+// InvalidationError reports the peers which failed to acknowledge the invalidation of a key,
+// they may keep a stale copy of the key until it expires
+type InvalidationError struct {
+	Key    string
+	Failed map[string]error // keyed by the address of peer
+}
+
+func (e *InvalidationError) Error() string {
+	addrs := make([]string, 0, len(e.Failed))
+	for addr := range e.Failed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	msgs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		msgs[i] = fmt.Sprintf("%s: %v", addr, e.Failed[addr])
+	}
+	return fmt.Sprintf("failed to invalidate %s on %d peers: %s", e.Key, len(addrs), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,56 @@
+package geek
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// forwardedHeader marks the calls which a peer forwards to the owner of the key
+const forwardedHeader = "x-geek-forwarded"
+
+// forwardedFlightPrefix separates the singleflight keys of the forwarded loads from the local ones
+const forwardedFlightPrefix = "\x00forwarded:"
+
+type forwardedKey struct{}
+
+// forwardTo marks the call to the peer as forwarded, so that the peer never forwards it again
+func forwardTo(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, forwardedHeader, "1")
+}
+
+// isForwarded returns true if the call served is forwarded by a peer
+func isForwarded(ctx context.Context) bool {
+	forwarded, _ := ctx.Value(forwardedKey{}).(bool)
+	return forwarded
+}
+
+// forwardingUnaryServerInterceptor marks the context of the calls forwarded by the peers,
+// they're served locally even if the rings of the peers disagree, rather than bouncing between them
+func forwardingUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(forwardedHeader)) > 0 {
+			ctx = context.WithValue(ctx, forwardedKey{}, true)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// checkForwarded counts the forwarded key if self doesn't own it by its own ring,
+// i.e. the ring of the peer forwarding it disagrees with self
+func (g *Group) checkForwarded(key string) {
+	if g.peers == nil {
+		return
+	}
+	if _, ok := g.pickReplicas(key); ok {
+		if g.isReplica(key) {
+			return
+		}
+	} else if _, ok, isSelf := g.peers.PickPeer(key); !ok || isSelf {
+		return
+	}
+	atomic.AddInt64(&g.stats.misroutes, 1)
+	g.logger.Debug("serve the key forwarded by a peer disagreeing about the owner", "group", g.name, "key", key)
+}
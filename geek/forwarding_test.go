@@ -0,0 +1,88 @@
+package geek
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startDisagreeingGroups starts two nodes, each of them thinks the other one owns all the keys
+func startDisagreeingGroups(t *testing.T, getter Getter) []*Group {
+	a := assert.New(t)
+	groups := make([]*Group, 2)
+	addrs := make([]string, 2)
+	for i := range groups {
+		groups[i] = NewGroup("forwarding", 2<<10, getter, PrivateForTesting())
+		_, addrs[i] = serveGroup(t, groups[i])
+	}
+	for i, g := range groups {
+		client, err := NewClient(addrs[1-i], defaultServiceName)
+		a.Nil(err)
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		g.RegisterPeers(&singlePeerPicker{peer: client})
+	}
+	return groups
+}
+
+func TestForwarding_Disagree(t *testing.T) {
+	a := assert.New(t)
+	var loads int64
+	getter := GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		atomic.AddInt64(&loads, 1)
+		return []byte("db" + key), true, time.Time{}
+	})
+	groups := startDisagreeingGroups(t, getter)
+
+	// the forwarded key is served by the peer, rather than bouncing back
+	start := time.Now()
+	v, err := groups[0].Get("Tom")
+	a.Nil(err)
+	a.Equal("dbTom", v.String())
+	a.True(time.Since(start) < time.Second)
+	a.Equal(int64(1), atomic.LoadInt64(&loads))
+	a.Equal(int64(1), groups[1].Stats().Misroutes)
+
+	results := groups[0].GetMany([]string{"Jack", "Mike"})
+	for _, r := range results {
+		a.Nil(r.Err)
+	}
+	a.Equal(int64(3), groups[1].Stats().Misroutes)
+
+	ok, err := groups[0].Set("Lucy", []byte("set"), 0)
+	a.True(ok)
+	a.Nil(err)
+	// kept by the peer which the key is forwarded to
+	v, _, found := groups[1].peekLocally("Lucy")
+	a.True(found)
+	a.Equal("set", v.String())
+}
+
+func TestForwarding_DisagreeConcurrent(t *testing.T) {
+	a := assert.New(t)
+	getter := GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		// slow enough for the loads on both nodes to overlap
+		time.Sleep(50 * time.Millisecond)
+		return []byte("db" + key), true, time.Time{}
+	})
+	groups := startDisagreeingGroups(t, getter)
+
+	// both nodes load the key at once, each of them forwards it to the other one
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(g *Group) {
+			defer wg.Done()
+			v, err := g.Get("Tom")
+			a.Nil(err)
+			a.Equal("dbTom", v.String())
+		}(groups[i%2])
+	}
+	wg.Wait()
+	a.True(time.Since(start) < time.Second)
+}
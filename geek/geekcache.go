@@ -1,12 +1,19 @@
 package geek
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	c "github.com/Makonike/geek-cache/geek/cache"
+	"github.com/Makonike/geek-cache/geek/logger"
 	"github.com/Makonike/geek-cache/geek/singleflight"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -16,10 +23,27 @@ var (
 
 type Group struct {
 	name      string              // group name
-	getter    Getter              // 缓存未名中时的callback
+	getter    ContextGetter       // 缓存未名中时的callback
 	mainCache cache               // main cache
+	hotCache  cache               // cache of the hot keys fetched from peers, disabled if its cacheBytes is 0
+	hotTTL    time.Duration       // the expiration of the values in hotCache
+	hotRate   float64             // the fraction of the values fetched from peers to be kept in hotCache
+	negCache  cache               // cache of the keys not found by the Getter, disabled if its cacheBytes is 0
+	negTTL    time.Duration       // the expiration of the keys in negCache
 	peers     PeerPicker          // pick function
 	loader    *singleflight.Group // make sure that each key is only fetched once
+	private   bool                // not registered to groups
+	logger    logger.Logger
+	tracer    trace.Tracer
+	stats     groupStats
+	// handoff sends the entries to their new owners when the ring changes,
+	// and the keys missed are asked from their previous owners within handoffFallback
+	handoff         bool
+	handoffFallback time.Duration
+	previous        atomic.Value // *previousRing
+	replicas        int          // the number of peers holding each key, 1 if the replication is off
+	unwatchRing     func()       // stops watching the ring, nil if it's not watched
+	closed          int32        // set by Close, accessed by atomic
 }
 
 func (g *Group) RegisterPeers(peers PeerPicker) {
@@ -27,28 +51,142 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 		panic("RegisterPeerPicker called multiple times")
 	}
 	g.peers = peers
+	if w, ok := peers.(RingWatcher); ok && g.handoff {
+		g.unwatchRing = w.WatchRing(g.onRingChange)
+	}
 }
 
+type GroupOptions func(*Group)
+
 // NewGroup 新创建一个Group
 // 如果存在同名的group会进行覆盖
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+// the replaced group is closed
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOptions) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
-	lock.Lock()
-	defer lock.Unlock()
 	g := &Group{
 		name:   name,
-		getter: getter,
+		getter: contextGetter(getter),
 		mainCache: cache{
 			cacheBytes: cacheBytes,
+			algorithm:  c.LRU,
+			policy:     c.ALLKEYS_LRU,
 		},
 		loader: &singleflight.Group{},
+		logger: logger.Default(),
+		tracer: newTracer(nil),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.mainCache.policy != c.ALLKEYS_LRU && (g.mainCache.algorithm == c.LFU || g.mainCache.algorithm == c.TINY_LFU) {
+		panic("MemoryPolicy only works with c.LRU")
 	}
+	g.hotCache.expiry = g.mainCache.expiry
+	g.negCache.expiry = g.mainCache.expiry
+	if g.private {
+		return g
+	}
+	lock.Lock()
+	replaced := groups[name]
 	groups[name] = g
+	lock.Unlock()
+	if replaced != nil {
+		_ = replaced.Close()
+	}
 	return g
 }
 
+// MemoryPolicy sets the policy to evict keys when cacheBytes is exceeded
+// e.g. c.VOLATILE_LRU keeps the keys without expiration, only works with c.LRU,
+// NewGroup panics if it's set with c.LFU or c.TINY_LFU
+func MemoryPolicy(policy c.MaxMemoryPolicy) GroupOptions {
+	return func(g *Group) {
+		g.mainCache.policy = policy
+	}
+}
+
+// CacheShards splits the cache of the group into n independently locked segments,
+// use it when lots of concurrent Get calls contend for the lock of the cache
+func CacheShards(n int) GroupOptions {
+	return func(g *Group) {
+		g.mainCache.shards = n
+	}
+}
+
+// HotKeyCache keeps a sampled fraction (rate, 0~1) of the values fetched from peers in a local cache,
+// so that a hot key doesn't hammer its owner. Delete and Set broadcast the invalidation to drop them,
+// and ttl bounds how long they stay stale if the invalidation is lost.
+func HotKeyCache(cacheBytes int64, ttl time.Duration, rate float64) GroupOptions {
+	return func(g *Group) {
+		g.hotCache = cache{
+			cacheBytes: cacheBytes,
+			algorithm:  c.LRU,
+			policy:     c.ALLKEYS_LRU,
+		}
+		g.hotTTL = ttl
+		g.hotRate = rate
+	}
+}
+
+// GroupLogger sets the logger of the group, logger.Default() by default
+func GroupLogger(l logger.Logger) GroupOptions {
+	return func(g *Group) {
+		g.logger = l
+	}
+}
+
+// GroupTracerProvider sets where the spans of the group are created, e.g. the spans of load and Getter calls.
+// The spans are not recorded by default
+func GroupTracerProvider(tp trace.TracerProvider) GroupOptions {
+	return func(g *Group) {
+		g.tracer = newTracer(tp)
+	}
+}
+
+// PrivateForTesting keeps the group out of GetGroup, it's served by the Server with ServerGroupsForTesting.
+// It's meant for geektest, which runs several nodes of a cluster with the same group in one process,
+// and not for the applications
+func PrivateForTesting() GroupOptions {
+	return func(g *Group) {
+		g.private = true
+	}
+}
+
+// NotFoundCache remembers the keys which the Getter reports as not found for ttl,
+// so that lookups for nonexistent keys don't go to the Getter every time.
+// ErrNotFound is returned for them. NewGroup panics if ttl is not positive
+func NotFoundCache(cacheBytes int64, ttl time.Duration) GroupOptions {
+	return func(g *Group) {
+		if ttl <= 0 {
+			panic("NotFoundCache requires a positive ttl")
+		}
+		g.negCache = cache{
+			cacheBytes: cacheBytes,
+			algorithm:  c.LRU,
+			policy:     c.ALLKEYS_LRU,
+		}
+		g.negTTL = ttl
+	}
+}
+
+// CacheExpiryInterval sets how often the expired keys of the caches are removed, 1 second by default,
+// so that the keys expired but never read don't take the space of the live ones
+func CacheExpiryInterval(interval time.Duration) GroupOptions {
+	return func(g *Group) {
+		g.mainCache.expiry = interval
+	}
+}
+
+// CacheAlgorithm sets the replacement algorithm of the group, c.LRU by default
+// c.TINY_LFU keeps the hot keys from being flushed by one-off scans
+func CacheAlgorithm(algorithm c.Algorithm) GroupOptions {
+	return func(g *Group) {
+		g.mainCache.algorithm = algorithm
+	}
+}
+
 func GetGroup(name string) *Group {
 	lock.RLock()
 	g := groups[name]
@@ -57,34 +195,94 @@ func GetGroup(name string) *Group {
 }
 
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, the deadline and cancellation of ctx
+// are propagated to the peer and the Getter
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
-	return g.load(key)
+	if g.isClosed() {
+		return ByteView{}, ErrGroupClosed
+	}
+	atomic.AddInt64(&g.stats.gets, 1)
+	if v, ok := g.lookupHotCache(key); ok {
+		atomic.AddInt64(&g.stats.cacheHits, 1)
+		return v, nil
+	}
+	return g.load(ctx, key)
+}
+
+// CacheStats returns the statistics of the cache
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.stats()
+	case HotCache:
+		return g.hotCache.stats()
+	case NegativeCache:
+		return g.negCache.stats()
+	default:
+		return CacheStats{}
+	}
 }
 
 // get from peer first, then get locally
-func (g *Group) load(key string) (ByteView, error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	ctx, span := g.tracer.Start(ctx, "geek.Group.load", trace.WithAttributes(
+		attribute.String("geek.group", g.name), attribute.String("geek.key", key)))
+	defer func() { endSpan(span, err) }()
+
 	// make sure requests for the key only execute once in concurrent condition
-	v, err := g.loader.Do(key, func() (interface{}, error) {
+	executed := false
+	forwarded := isForwarded(ctx)
+	flight := key
+	if forwarded {
+		// the forwarded loads never wait for a local load forwarding the key to the peer,
+		// which waits for them in turn if the rings disagree
+		flight = forwardedFlightPrefix + key
+	}
+	ctx, wait := g.tracer.Start(ctx, "geek.Group.singleflight")
+	v, err := g.loader.DoContext(ctx, flight, func(ctx context.Context) (interface{}, error) {
+		executed = true
+		if forwarded {
+			// the peer has routed the key to self, never forward it again
+			g.checkForwarded(key)
+			return g.getLocally(ctx, key)
+		}
+		if replicas, ok := g.pickReplicas(key); ok {
+			return g.loadFromReplicas(ctx, key, replicas)
+		}
 		if g.peers != nil {
-			if peer, ok, isSelf := g.peers.PickPeer(key); ok {
-				if isSelf {
-					if v, ok := g.mainCache.get(key); ok {
-						log.Println("[Geek-Cache] hit")
-						return v, nil
-					}
+			_, pick := g.tracer.Start(ctx, "geek.Group.pickPeer")
+			peer, ok, isSelf := g.peers.PickPeer(key)
+			pick.End()
+			// the keys owned by self are looked up by getLocally
+			if ok && !isSelf {
+				if value, err := g.getFromPeer(ctx, peer, key); err == nil {
+					atomic.AddInt64(&g.stats.peerLoads, 1)
+					g.populateHotCache(key, value)
+					return value, nil
+				} else if errors.Is(err, ErrNotFound) {
+					// the owner has asked the Getter
+					atomic.AddInt64(&g.stats.peerLoads, 1)
+					return nil, err
 				} else {
-					if value, err := g.getFromPeer(peer, key); err == nil {
-						return value, nil
-					} else {
-						log.Println("[Geek-Cache] Failed to get from peer", err)
-					}
+					atomic.AddInt64(&g.stats.peerErrors, 1)
+					g.logger.Warn("failed to get from peer", "group", g.name, "key", key, "err", err)
 				}
 			}
 		}
-		return g.getLocally(key)
+		return g.getLocally(ctx, key)
 	})
+	// the callers which waited for the load of another one are deduplicated
+	wait.SetAttributes(attribute.Bool("geek.dedup", !executed))
+	endSpan(wait, err)
+	if !executed {
+		atomic.AddInt64(&g.stats.dedups, 1)
+	}
 
 	if err == nil {
 		return v.(ByteView), nil
@@ -92,12 +290,31 @@ func (g *Group) load(key string) (ByteView, error) {
 	return ByteView{}, err
 }
 
+// Delete deletes the key on the peer which owns it,
+// then the owner broadcasts the invalidation of the key to all peers.
+// An *InvalidationError is returned with true if some peers failed to acknowledge it
 func (g *Group) Delete(key string) (bool, error) {
+	return g.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete, the deadline and cancellation of ctx are propagated to the peer
+func (g *Group) DeleteContext(ctx context.Context, key string) (bool, error) {
 	if key == "" {
 		return true, fmt.Errorf("key is required")
 	}
+	if g.isClosed() {
+		return false, ErrGroupClosed
+	}
+	g.removeHotCache(key)
+	if isForwarded(ctx) {
+		g.checkForwarded(key)
+		success := g.mainCache.delete(key)
+		g.removeNegCache(key)
+		return success, g.broadcastInvalidation(ctx, key)
+	}
 	// Peer is not set, delete from local
 	if g.peers == nil {
+		g.removeNegCache(key)
 		return g.mainCache.delete(key), nil
 	}
 	// The peer is set,
@@ -106,16 +323,92 @@ func (g *Group) Delete(key string) (bool, error) {
 		return false, nil
 	}
 	if isSelf {
-		return g.mainCache.delete(key), nil
+		success := g.mainCache.delete(key)
+		g.removeNegCache(key)
+		return success, g.broadcastInvalidation(ctx, key)
 	} else {
 		//use other server to delete the key-value
-		success, err := g.deleteFromPeer(peer, key)
+		success, err := g.deleteFromPeer(ctx, peer, key)
 		return success, err
 	}
 }
 
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
+// Set puts the key-value into the cache of the peer which owns the key,
+// without calling the Getter, then the owner broadcasts the invalidation of the key to all peers like Delete.
+// The key never expires if ttl is 0. An *InvalidationError is returned with true if some peers failed to acknowledge it
+func (g *Group) Set(key string, value []byte, ttl time.Duration) (bool, error) {
+	return g.SetContext(context.Background(), key, value, ttl)
+}
+
+// SetContext is like Set, the deadline and cancellation of ctx are propagated to the peer
+func (g *Group) SetContext(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	if key == "" {
+		return false, fmt.Errorf("key is required")
+	}
+	if g.isClosed() {
+		return false, ErrGroupClosed
+	}
+	if isForwarded(ctx) {
+		g.checkForwarded(key)
+		return g.setLocally(key, value, ttl), g.broadcastInvalidation(ctx, key)
+	}
+	if replicas, ok := g.pickReplicas(key); ok {
+		return g.setReplicas(ctx, key, value, ttl, replicas)
+	}
+	if g.peers != nil {
+		if peer, ok, isSelf := g.peers.PickPeer(key); ok && !isSelf {
+			//use other server to set the key-value, the owner drops the hot copies of the others
+			g.removeHotCache(key)
+			return g.setToPeer(ctx, peer, key, value, ttl)
+		}
+	}
+	// the hot copies of the other peers are stale now, as Delete does
+	return g.setLocally(key, value, ttl), g.broadcastInvalidation(ctx, key)
+}
+
+// broadcastInvalidation asks all peers to drop the key from their local caches in parallel,
+// e.g. the hot copies, or the copies left over after the owner changed
+func (g *Group) broadcastInvalidation(ctx context.Context, key string) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := make(map[string]error)
+	for addr, peer := range g.listPeers() {
+		wg.Add(1)
+		go func(addr string, peer PeerGetter) {
+			defer wg.Done()
+			if err := peer.Invalidate(ctx, g.name, key); err != nil {
+				mu.Lock()
+				failed[addr] = err
+				mu.Unlock()
+			}
+		}(addr, peer)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return &InvalidationError{Key: key, Failed: failed}
+	}
+	return nil
+}
+
+// listPeers returns all peers except self, nil if the PeerPicker doesn't implement PeerLister
+func (g *Group) listPeers() map[string]PeerGetter {
+	if lister, ok := g.peers.(PeerLister); ok {
+		return lister.Peers()
+	}
+	return nil
+}
+
+// invalidateLocally drops the key from all caches of this peer
+func (g *Group) invalidateLocally(key string) {
+	g.mainCache.delete(key)
+	g.removeHotCache(key)
+	g.removeNegCache(key)
+}
+
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (_ ByteView, err error) {
+	ctx, span := g.tracer.Start(ctx, "geek.Group.getFromPeer")
+	defer func() { endSpan(span, err) }()
+	bytes, err := peer.Get(forwardTo(ctx), g.name, key)
 	if err != nil {
 		return ByteView{}, err
 	}
@@ -124,31 +417,112 @@ func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 	}, nil
 }
 
-func (g *Group) deleteFromPeer(peer PeerGetter, key string) (bool, error) {
-	success, err := peer.Delete(g.name, key)
+func (g *Group) deleteFromPeer(ctx context.Context, peer PeerGetter, key string) (bool, error) {
+	success, err := peer.Delete(forwardTo(ctx), g.name, key)
+	if _, ok := err.(*InvalidationError); ok {
+		// the key is deleted by the owner
+		return success, err
+	}
 	if err != nil {
 		return false, err
 	}
 	return success, nil
 }
 
-func (g *Group) getLocally(key string) (ByteView, error) {
-	// have a try again
+func (g *Group) setToPeer(ctx context.Context, peer PeerGetter, key string, value []byte, ttl time.Duration) (bool, error) {
+	success, err := peer.Set(forwardTo(ctx), g.name, key, value, ttl)
+	if _, ok := err.(*InvalidationError); ok {
+		// the key is set by the owner
+		return success, err
+	}
+	if err != nil {
+		return false, err
+	}
+	return success, nil
+}
+
+func (g *Group) getLocally(ctx context.Context, key string) (_ ByteView, err error) {
+	ctx, span := g.tracer.Start(ctx, "geek.Group.getLocally")
+	defer func() { endSpan(span, err) }()
 	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[Geek-Cache] hit")
+		g.logger.Debug("hit", "group", g.name, "key", key)
+		atomic.AddInt64(&g.stats.cacheHits, 1)
+		return v, nil
+	}
+	return g.loadLocally(ctx, key)
+}
+
+// loadLocally loads the key missed by the main cache, the caller has looked it up
+func (g *Group) loadLocally(ctx context.Context, key string) (ByteView, error) {
+	if g.negCache.cacheBytes > 0 {
+		if _, ok := g.negCache.get(key); ok {
+			return ByteView{}, ErrNotFound
+		}
+	}
+	if v, ok := g.getFromPreviousOwner(ctx, key); ok {
 		return v, nil
 	}
-	bytes, f, expirationTime := g.getter.Get(key)
+	bytes, f, expirationTime := g.getter.GetContext(ctx, key)
 	if !f {
-		return ByteView{}, fmt.Errorf("data not found")
+		atomic.AddInt64(&g.stats.localLoadErrs, 1)
+		if err := ctx.Err(); err != nil {
+			// the Getter gave up because of the caller
+			return ByteView{}, err
+		}
+		if g.negCache.cacheBytes > 0 {
+			g.negCache.addWithExpiration(key, ByteView{}, time.Now().Add(g.negTTL))
+		}
+		return ByteView{}, ErrNotFound
 	}
+	atomic.AddInt64(&g.stats.localLoads, 1)
 	bw := ByteView{cloneBytes(bytes)}
+	g.populateCache(key, bw, expirationTime)
+	g.replicate(key, bw, expirationTime)
+	return bw, nil
+}
+
+func (g *Group) populateCache(key string, value ByteView, expirationTime time.Time) {
+	if g.isClosed() {
+		// e.g. a load in flight when the group is closed
+		return
+	}
+	g.removeNegCache(key)
 	if !expirationTime.IsZero() {
-		g.mainCache.addWithExpiration(key, bw, expirationTime)
+		g.mainCache.addWithExpiration(key, value, expirationTime)
 	} else {
-		g.mainCache.add(key, bw)
+		g.mainCache.add(key, value)
+	}
+}
+
+func (g *Group) lookupHotCache(key string) (ByteView, bool) {
+	if g.hotCache.cacheBytes <= 0 {
+		return ByteView{}, false
+	}
+	return g.hotCache.get(key)
+}
+
+// keep a sampled fraction of the values from peers
+func (g *Group) populateHotCache(key string, value ByteView) {
+	if g.hotCache.cacheBytes <= 0 || rand.Float64() >= g.hotRate {
+		return
+	}
+	if g.hotTTL > 0 {
+		g.hotCache.addWithExpiration(key, value, time.Now().Add(g.hotTTL))
+	} else {
+		g.hotCache.add(key, value)
+	}
+}
+
+func (g *Group) removeHotCache(key string) {
+	if g.hotCache.cacheBytes > 0 {
+		g.hotCache.delete(key)
+	}
+}
+
+func (g *Group) removeNegCache(key string) {
+	if g.negCache.cacheBytes > 0 {
+		g.negCache.delete(key)
 	}
-	return bw, nil
 }
 
 // Getter loads data for a key locally
@@ -164,10 +538,64 @@ func (f GetterFunc) Get(key string) ([]byte, bool, time.Time) {
 	return f(key)
 }
 
+// ContextGetter is a Getter which can be canceled by the context of the caller,
+// e.g. pass ctx to a slow database query. The deadline of a peer request is kept in ctx
+type ContextGetter interface {
+	GetContext(ctx context.Context, key string) ([]byte, bool, time.Time)
+}
+
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, bool, time.Time)
+
+func (f ContextGetterFunc) Get(key string) ([]byte, bool, time.Time) {
+	return f(context.Background(), key)
+}
+
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, bool, time.Time) {
+	return f(ctx, key)
+}
+
+// contextGetter adapts a Getter to ContextGetter, the context is ignored if it doesn't support
+func contextGetter(getter Getter) ContextGetter {
+	if cg, ok := getter.(ContextGetter); ok {
+		return cg
+	}
+	return ContextGetterFunc(func(_ context.Context, key string) ([]byte, bool, time.Time) {
+		return getter.Get(key)
+	})
+}
+
+// DestroyGroup removes the group from GetGroup and closes it
 func DestroyGroup(name string) {
-	g := GetGroup(name)
+	lock.Lock()
+	g := groups[name]
+	delete(groups, name)
+	lock.Unlock()
 	if g != nil {
-		delete(groups, name)
-		log.Printf("Destroy cache [%s]", name)
+		_ = g.Close()
+		g.logger.Info("destroy cache", "group", name)
 	}
 }
+
+// Close stops the background work of the group, e.g. the periodic clean of its caches and the watch of the ring,
+// purges its caches, and removes it from GetGroup. The calls after Close return ErrGroupClosed, so does Close itself
+func (g *Group) Close() error {
+	if !atomic.CompareAndSwapInt32(&g.closed, 0, 1) {
+		return ErrGroupClosed
+	}
+	lock.Lock()
+	if groups[g.name] == g {
+		delete(groups, g.name)
+	}
+	lock.Unlock()
+	if g.unwatchRing != nil {
+		g.unwatchRing()
+	}
+	g.mainCache.close()
+	g.hotCache.close()
+	g.negCache.close()
+	return nil
+}
+
+func (g *Group) isClosed() bool {
+	return atomic.LoadInt32(&g.closed) == 1
+}
@@ -1,10 +1,17 @@
 package geek
 
 import (
+	"bytes"
+	"context"
+	"log"
 	"math/rand"
+	"runtime"
+	"sync/atomic"
 	"testing"
 	time "time"
 
+	c "github.com/Makonike/geek-cache/geek/cache"
+	"github.com/Makonike/geek-cache/geek/logger"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,6 +53,26 @@ func TestGroup_Get(t *testing.T) {
 	}
 }
 
+func TestGroup_MainCacheStats(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("main-stats", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}), PrivateForTesting())
+	// keys starting with "s" are owned by self
+	g.RegisterPeers(&fakePicker{})
+
+	// each Get looks up the main cache once, the miss included
+	for i := 0; i < 2; i++ {
+		v, err := g.Get("s1")
+		a.Nil(err)
+		a.Equal("dbs1", v.String())
+	}
+	stats := g.CacheStats(MainCache)
+	a.Equal(int64(2), stats.Gets)
+	a.Equal(int64(1), stats.Hits)
+}
+
 func TestGroup_Delete(t *testing.T) {
 	a := assert.New(t)
 	database := map[string]string{
@@ -113,3 +140,278 @@ func TestGroup_SetTimeout(t *testing.T) {
 	a.Equal(v2.String(), "123")
 
 }
+
+func TestGroup_CacheAlgorithm(t *testing.T) {
+	a := assert.New(t)
+	for _, opt := range []GroupOptions{CacheAlgorithm(c.LRU), CacheAlgorithm(c.LFU), CacheAlgorithm(c.TINY_LFU), CacheShards(8)} {
+		loads := make(map[string]int)
+		gee := NewGroup("algorithm", 2<<10, GetterFunc(
+			func(key string) ([]byte, bool, time.Time) {
+				if v, ok := db[key]; ok {
+					loads[key] += 1
+					return []byte(v), true, time.Time{}
+				}
+				return nil, false, time.Time{}
+			}),
+			opt,
+		)
+		for k, v := range db {
+			view, err := gee.Get(k)
+			a.Nil(err)
+			a.Equal(v, view.String())
+			// load from cache
+			_, _ = gee.Get(k)
+			a.Equal(1, loads[k])
+		}
+	}
+}
+
+func TestGroup_Set(t *testing.T) {
+	a := assert.New(t)
+	loads := make(map[string]int)
+	gee := NewGroup("scores", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			loads[key] += 1
+			if v, ok := db[key]; ok {
+				return []byte(v), true, time.Time{}
+			}
+			return nil, false, time.Time{}
+		}),
+	)
+	_, err := gee.Set("", []byte("1"), 0)
+	a.NotNil(err)
+	// 写入后可以直接读取, 不会调用Getter
+	s, err := gee.Set("Bob", []byte("599"), 0)
+	a.True(s)
+	a.Nil(err)
+	view, err := gee.Get("Bob")
+	a.Nil(err)
+	a.Equal("599", view.String())
+	a.Equal(0, loads["Bob"])
+	// 覆盖已有的值
+	_, _ = gee.Get("Tom")
+	_, _ = gee.Set("Tom", []byte("700"), 0)
+	view, _ = gee.Get("Tom")
+	a.Equal("700", view.String())
+	a.Equal(1, loads["Tom"])
+	// 过期后重新调用Getter
+	_, _ = gee.Set("Jack", []byte("800"), time.Second)
+	view, _ = gee.Get("Jack")
+	a.Equal("800", view.String())
+	time.Sleep(time.Second)
+	view, _ = gee.Get("Jack")
+	a.Equal("742", view.String())
+	a.Equal(1, loads["Jack"])
+}
+
+func TestGroup_GetContext(t *testing.T) {
+	a := assert.New(t)
+	gee := NewGroup("context", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, bool, time.Time) {
+			// slow database
+			select {
+			case <-ctx.Done():
+				return nil, false, time.Time{}
+			case <-time.After(100 * time.Millisecond):
+			}
+			if v, ok := db[key]; ok {
+				return []byte(v), true, time.Time{}
+			}
+			return nil, false, time.Time{}
+		}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := gee.GetContext(ctx, "Tom")
+	a.Equal(context.DeadlineExceeded, err)
+	// the canceled load is not cached
+	view, err := gee.Get("Tom")
+	a.Nil(err)
+	a.Equal("630", view.String())
+	_, err = gee.GetContext(context.Background(), "unknown")
+	a.NotNil(err)
+}
+
+func TestGroup_DeleteBroadcast(t *testing.T) {
+	a := assert.New(t)
+	gee := NewGroup("broadcast", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}))
+	picker := &fakePicker{peers: map[byte]*fakePeer{
+		'a': {name: "A"},
+		'c': {name: "C", fail: true},
+	}}
+	gee.RegisterPeers(picker)
+
+	// the owner broadcasts the invalidation to all peers
+	s, err := gee.Delete("s1")
+	a.True(s)
+	invalidationErr, ok := err.(*InvalidationError)
+	a.True(ok)
+	a.Equal("s1", invalidationErr.Key)
+	a.Equal(1, len(invalidationErr.Failed))
+	a.NotNil(invalidationErr.Failed["C"])
+	a.Equal([]string{"s1"}, picker.peers['a'].invalidated)
+	a.Equal([]string{"s1"}, picker.peers['c'].invalidated)
+
+	// the invalidation is not broadcast by peers which don't own the key
+	_, err = gee.Delete("a1")
+	a.Nil(err)
+	a.Equal([]string{"s1"}, picker.peers['a'].invalidated)
+}
+
+func TestGroup_SetBroadcast(t *testing.T) {
+	a := assert.New(t)
+	gee := NewGroup("broadcast-set", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}), PrivateForTesting())
+	picker := &fakePicker{peers: map[byte]*fakePeer{
+		'a': {name: "A"},
+		'c': {name: "C", fail: true},
+	}}
+	gee.RegisterPeers(picker)
+
+	// the owner broadcasts the invalidation to all peers, the key is set anyway
+	s, err := gee.Set("s1", []byte("630"), 0)
+	a.True(s)
+	invalidationErr, ok := err.(*InvalidationError)
+	a.True(ok)
+	a.NotNil(invalidationErr.Failed["C"])
+	a.Equal([]string{"s1"}, picker.peers['a'].invalidated)
+	view, err := gee.Get("s1")
+	a.Nil(err)
+	a.Equal("630", view.String())
+
+	// the invalidation is not broadcast by peers which don't own the key
+	_, err = gee.Set("a1", []byte("630"), 0)
+	a.Nil(err)
+	a.Equal([]string{"s1"}, picker.peers['a'].invalidated)
+}
+
+func TestGroup_NotFoundCache(t *testing.T) {
+	a := assert.New(t)
+	loads := make(map[string]int)
+	gee := NewGroup("scores", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			loads[key] += 1
+			if v, ok := db[key]; ok {
+				return []byte(v), true, time.Time{}
+			}
+			return nil, false, time.Time{}
+		}),
+		NotFoundCache(1<<10, time.Second),
+	)
+	// 不存在的key只会调用一次Getter
+	for i := 0; i < 3; i++ {
+		_, err := gee.Get("unknown")
+		a.ErrorIs(err, ErrNotFound)
+	}
+	a.Equal(1, loads["unknown"])
+	a.Equal(int64(2), gee.CacheStats(NegativeCache).Hits)
+	// 过期后重新调用Getter
+	time.Sleep(time.Second)
+	_, err := gee.Get("unknown")
+	a.ErrorIs(err, ErrNotFound)
+	a.Equal(2, loads["unknown"])
+	// 写入后清除
+	_, _ = gee.Set("unknown", []byte("1"), 0)
+	view, err := gee.Get("unknown")
+	a.Nil(err)
+	a.Equal("1", view.String())
+	_, _ = gee.Delete("unknown")
+	_, err = gee.Get("unknown")
+	a.ErrorIs(err, ErrNotFound)
+	a.Equal(3, loads["unknown"])
+
+	// the keys not found would expire at once
+	a.Panics(func() {
+		NewGroup("scores-negative", 2<<10, GetterFunc(
+			func(key string) ([]byte, bool, time.Time) {
+				return nil, false, time.Time{}
+			}), NotFoundCache(1<<10, 0), PrivateForTesting())
+	})
+}
+
+func TestGroup_Logger(t *testing.T) {
+	a := assert.New(t)
+	var buf bytes.Buffer
+	gee := NewGroup("logger", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte(key), true, time.Time{}
+		}),
+		GroupLogger(logger.New(log.New(&buf, "", 0), logger.DEBUG)),
+	)
+	_, _ = gee.Get("Tom")
+	a.Empty(buf.String())
+	_, _ = gee.Get("Tom")
+	a.Equal("[DEBUG] hit group=logger key=Tom\n", buf.String())
+}
+
+func TestGroup_Close(t *testing.T) {
+	a := assert.New(t)
+	getter := GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte(key), true, time.Time{}
+	})
+	g := NewGroup("close", 2<<10, getter)
+	_, err := g.Get("Tom")
+	a.Nil(err)
+	a.Nil(g.Close())
+	a.Nil(GetGroup("close"))
+	a.Equal(int64(0), g.Stats().MainCache.Items)
+	_, err = g.Get("Tom")
+	a.Equal(ErrGroupClosed, err)
+	_, err = g.Set("Tom", []byte("630"), 0)
+	a.Equal(ErrGroupClosed, err)
+	_, err = g.Delete("Tom")
+	a.Equal(ErrGroupClosed, err)
+	a.Equal(ErrGroupClosed, g.GetMany([]string{"Tom"})[0].Err)
+	a.Equal(ErrGroupClosed, g.Close())
+
+	// the replaced group is closed
+	old := NewGroup("close", 2<<10, getter)
+	g = NewGroup("close", 2<<10, getter)
+	a.Equal(g, GetGroup("close"))
+	_, err = old.Get("Tom")
+	a.Equal(ErrGroupClosed, err)
+	DestroyGroup("close")
+	a.Nil(GetGroup("close"))
+	_, err = g.Get("Tom")
+	a.Equal(ErrGroupClosed, err)
+
+	// the main cache of cacheBytes 0 is created by the lookups too, its expiry goroutine is stopped by Close
+	g = NewGroup("close-empty", 0, getter, PrivateForTesting())
+	_, err = g.Get("Tom")
+	a.Nil(err)
+	a.Equal(int32(1), atomic.LoadInt32(&g.mainCache.loaded))
+	before := runtime.NumGoroutine()
+	a.Nil(g.Close())
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() >= before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.Less(runtime.NumGoroutine(), before)
+}
+
+func TestGroup_DestroyConcurrently(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, bool, time.Time) {
+		return []byte(key), true, time.Time{}
+	})
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 100; j++ {
+				g := NewGroup("destroy", 2<<10, getter)
+				_, _ = g.Get("Tom")
+				_ = GetGroup("destroy")
+				DestroyGroup("destroy")
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	assert.Nil(t, GetGroup("destroy"))
+}
@@ -0,0 +1,244 @@
+// Package geektest runs a cluster of geek-cache nodes in one process for tests,
+// the nodes serve on loopback and find each other by an in-memory registry, so etcd is not required.
+package geektest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Makonike/geek-cache/geek"
+	"github.com/Makonike/geek-cache/geek/registry"
+)
+
+const (
+	serviceName = "geektest"
+	// how long to wait for the pickers to see the change of nodes
+	convergeTimeout = 5 * time.Second
+)
+
+// Cluster is a group of nodes, every node has its own Group, Server and ClientPicker
+type Cluster struct {
+	mu         sync.Mutex
+	group      string
+	cacheBytes int64
+	getter     geek.Getter
+	opts       []geek.GroupOptions
+	discovery  *registry.MemoryDiscovery
+	nodes      map[string]*Node // keyed by address
+}
+
+// Node is a node of the cluster
+type Node struct {
+	Addr     string
+	Group    *geek.Group
+	Server   *geek.Server
+	Picker   *geek.ClientPicker
+	listener *listener
+	served   chan error // the result of Serve
+}
+
+// NewCluster creates an empty cluster, the Group of each node is created by the arguments like NewGroup
+func NewCluster(group string, cacheBytes int64, getter geek.Getter, opts ...geek.GroupOptions) *Cluster {
+	return &Cluster{
+		group:      group,
+		cacheBytes: cacheBytes,
+		getter:     getter,
+		opts:       opts,
+		discovery:  registry.NewMemoryDiscovery(),
+		nodes:      make(map[string]*Node),
+	}
+}
+
+// Start adds n nodes
+func (c *Cluster) Start(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := c.AddNode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddNode starts a node on a random port of loopback,
+// and returns after all nodes see it
+func (c *Cluster) AddNode() (*Node, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	addr := l.Addr().String()
+	// the nodes share the group name in one process, so their groups are kept out of geek.GetGroup
+	opts := append([]geek.GroupOptions{geek.PrivateForTesting()}, c.opts...)
+	g := geek.NewGroup(c.group, c.cacheBytes, c.getter, opts...)
+	server, err := geek.NewServer(addr,
+		geek.ServiceName(serviceName),
+		geek.ServerDiscovery(c.discovery),
+		// the in-memory registry has no delay
+		geek.ServerDrainDelay(0),
+		geek.ServerGroupsForTesting(func(name string) *geek.Group {
+			if name == c.group {
+				return g
+			}
+			return nil
+		}))
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	picker := geek.NewClientPicker(addr, geek.PickerServiceName(serviceName), geek.PickerDiscovery(c.discovery))
+	g.RegisterPeers(picker)
+	n := &Node{
+		Addr:     addr,
+		Group:    g,
+		Server:   server,
+		Picker:   picker,
+		listener: &listener{Listener: l},
+		served:   make(chan error, 1),
+	}
+	go func() {
+		n.served <- server.Serve(n.listener)
+	}()
+
+	c.mu.Lock()
+	c.nodes[addr] = n
+	c.mu.Unlock()
+	return n, c.converge()
+}
+
+// RemoveNode stops the node gracefully, it leaves the ring of the others and its group is closed
+func (c *Cluster) RemoveNode(addr string) error {
+	c.mu.Lock()
+	n, ok := c.nodes[addr]
+	delete(c.nodes, addr)
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("node %s not found", addr)
+	}
+	err := n.Server.Stop(context.Background())
+	_ = n.Picker.Close()
+	// stops the expiry of the caches
+	_ = n.Group.Close()
+	if err != nil {
+		return err
+	}
+	if err := <-n.served; err != nil {
+		return err
+	}
+	return c.converge()
+}
+
+// KillNode drops all connections to the node and refuses the new ones,
+// the node is still registered and in the ring of the others, like a crashed or partitioned node.
+// RemoveNode cleans it up.
+func (c *Cluster) KillNode(addr string) error {
+	n := c.Node(addr)
+	if n == nil {
+		return fmt.Errorf("node %s not found", addr)
+	}
+	n.listener.kill()
+	return nil
+}
+
+// Node returns the node of addr, nil if not found
+func (c *Cluster) Node(addr string) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodes[addr]
+}
+
+// Nodes returns the nodes sorted by address
+func (c *Cluster) Nodes() []*Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nodes := make([]*Node, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Addr < nodes[j].Addr
+	})
+	return nodes
+}
+
+// Owner returns the address of the node which owns the key, the nodes agree on it after converged
+func (c *Cluster) Owner(key string) string {
+	for _, n := range c.Nodes() {
+		return n.Picker.Owner(key)
+	}
+	return ""
+}
+
+// Close removes all nodes, and closes their groups
+func (c *Cluster) Close() {
+	for _, n := range c.Nodes() {
+		_ = c.RemoveNode(n.Addr)
+	}
+}
+
+// converge waits until all nodes are registered, and the ring of every node has them
+func (c *Cluster) converge() error {
+	deadline := time.Now().Add(convergeTimeout)
+	for {
+		nodes := c.Nodes()
+		addrs := make([]string, len(nodes))
+		for i, n := range nodes {
+			addrs[i] = n.Addr
+		}
+		registered, _ := c.discovery.List(context.Background(), serviceName)
+		converged := reflect.DeepEqual(registered, addrs)
+		for _, n := range nodes {
+			if !reflect.DeepEqual(n.Picker.Members(), addrs) {
+				converged = false
+				break
+			}
+		}
+		if converged {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("nodes are not converged in %v", convergeTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// listener closes the connections at once after killed
+type listener struct {
+	net.Listener
+	mu     sync.Mutex
+	killed bool
+	conns  []net.Conn
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		l.mu.Lock()
+		if l.killed {
+			l.mu.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+		return conn, nil
+	}
+}
+
+func (l *listener) kill() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.killed = true
+	for _, conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.conns = nil
+}
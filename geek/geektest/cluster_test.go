@@ -0,0 +1,277 @@
+package geektest
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Makonike/geek-cache/geek"
+	"github.com/stretchr/testify/assert"
+)
+
+// source counts the loads of each key
+type source struct {
+	mu    sync.Mutex
+	loads map[string]int
+}
+
+func newSource() *source {
+	return &source{loads: make(map[string]int)}
+}
+
+func (s *source) Get(key string) ([]byte, bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads[key]++
+	return []byte("value of " + key), true, time.Time{}
+}
+
+func (s *source) count(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads[key]
+}
+
+func keys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	return keys
+}
+
+func TestCluster_Rebalance(t *testing.T) {
+	a := assert.New(t)
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src)
+	defer c.Close()
+	a.Nil(c.Start(3))
+	a.Equal(3, len(c.Nodes()))
+
+	// every key is loaded once by its owner, whichever node is asked
+	for _, key := range keys(20) {
+		for _, n := range c.Nodes() {
+			v, err := n.Group.Get(key)
+			a.Nil(err)
+			a.Equal("value of "+key, v.String())
+		}
+		a.Equal(1, src.count(key))
+	}
+
+	// some keys move to the new node
+	owners := make(map[string]string)
+	for _, key := range keys(20) {
+		owners[key] = c.Owner(key)
+	}
+	n, err := c.AddNode()
+	a.Nil(err)
+	moved := 0
+	for _, key := range keys(20) {
+		owner := c.Owner(key)
+		if owner != owners[key] {
+			a.Equal(n.Addr, owner)
+			moved++
+		}
+		for _, node := range c.Nodes() {
+			a.Equal(owner, node.Picker.Owner(key))
+		}
+	}
+	a.True(moved > 0)
+
+	// the keys of the removed node move to the others
+	a.Nil(c.RemoveNode(n.Addr))
+	for _, key := range keys(20) {
+		a.Equal(owners[key], c.Owner(key))
+		v, err := c.Nodes()[0].Group.Get(key)
+		a.Nil(err)
+		a.Equal("value of "+key, v.String())
+	}
+}
+
+func TestCluster_Failover(t *testing.T) {
+	a := assert.New(t)
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src)
+	defer c.Close()
+	a.Nil(c.Start(3))
+
+	key := "Tom"
+	owner := c.Owner(key)
+	a.Nil(c.KillNode(owner))
+	// the killed node is still in the ring, the others load the key by themselves
+	for _, n := range c.Nodes() {
+		if n.Addr == owner {
+			continue
+		}
+		a.Equal(owner, n.Picker.Owner(key))
+		v, err := n.Group.Get(key)
+		a.Nil(err)
+		a.Equal("value of Tom", v.String())
+	}
+	a.Equal(2, src.count(key))
+
+	// the owner leaves, and a new one owns the key
+	a.Nil(c.RemoveNode(owner))
+	a.NotEqual(owner, c.Owner(key))
+}
+
+func TestCluster_Delete(t *testing.T) {
+	a := assert.New(t)
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src, geek.HotKeyCache(1<<10, time.Minute, 1))
+	defer c.Close()
+	a.Nil(c.Start(3))
+
+	key := "Tom"
+	for _, n := range c.Nodes() {
+		_, err := n.Group.Get(key)
+		a.Nil(err)
+	}
+	a.Equal(1, src.count(key))
+
+	// delete on a node which doesn't own the key, it's routed to the owner
+	// and the hot copies on the others are dropped
+	for _, n := range c.Nodes() {
+		if n.Addr != c.Owner(key) {
+			s, err := n.Group.Delete(key)
+			a.True(s)
+			a.Nil(err)
+			break
+		}
+	}
+	for _, n := range c.Nodes() {
+		_, err := n.Group.Get(key)
+		a.Nil(err)
+	}
+	a.Equal(2, src.count(key))
+}
+
+func TestCluster_Set(t *testing.T) {
+	a := assert.New(t)
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src, geek.HotKeyCache(1<<10, time.Minute, 1))
+	defer c.Close()
+	a.Nil(c.Start(3))
+
+	// both the other nodes keep a hot copy
+	key := "Tom"
+	for _, n := range c.Nodes() {
+		_, err := n.Group.Get(key)
+		a.Nil(err)
+	}
+	a.Equal(1, src.count(key))
+
+	// set on a node which doesn't own the key, it's routed to the owner
+	// and the hot copy on the third node is dropped
+	var others []*Node
+	for _, n := range c.Nodes() {
+		if n.Addr != c.Owner(key) {
+			others = append(others, n)
+		}
+	}
+	a.Equal(2, len(others))
+	ok, err := others[0].Group.Set(key, []byte("set"), 0)
+	a.True(ok)
+	a.Nil(err)
+	for _, n := range c.Nodes() {
+		v, err := n.Group.Get(key)
+		a.Nil(err)
+		a.Equal("set", v.String())
+	}
+	a.Equal(1, src.count(key))
+}
+
+func TestCluster_Handoff(t *testing.T) {
+	a := assert.New(t)
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src, geek.Handoff(time.Minute))
+	defer c.Close()
+	a.Nil(c.Start(2))
+	for _, key := range keys(40) {
+		_, err := c.Nodes()[0].Group.Get(key)
+		a.Nil(err)
+	}
+
+	// the keys moved are sent to the new node, or asked from their previous owners
+	n, err := c.AddNode()
+	a.Nil(err)
+	a.Eventually(func() bool {
+		return n.Group.Stats().MainCache.Items > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, key := range keys(40) {
+		for _, node := range c.Nodes() {
+			v, err := node.Group.Get(key)
+			a.Nil(err)
+			a.Equal("value of "+key, v.String())
+		}
+		a.Equal(1, src.count(key))
+	}
+}
+
+func TestCluster_Replication(t *testing.T) {
+	a := assert.New(t)
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src, geek.Replication(2))
+	defer c.Close()
+	a.Nil(c.Start(3))
+
+	// the value loaded by the owner is written to the other replica
+	key := "Tom"
+	_, err := c.Nodes()[0].Group.Get(key)
+	a.Nil(err)
+	items := func() int64 {
+		n := int64(0)
+		for _, node := range c.Nodes() {
+			n += node.Group.Stats().MainCache.Items
+		}
+		return n
+	}
+	a.Eventually(func() bool { return items() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// Set writes all the replicas
+	ok, err := c.Nodes()[0].Group.Set("Jack", []byte("set"), 0)
+	a.True(ok)
+	a.Nil(err)
+	a.Equal(int64(4), items())
+
+	// the owner dies, the keys are served by the other replica
+	owner := c.Owner(key)
+	a.Nil(c.KillNode(owner))
+	for _, n := range c.Nodes() {
+		if n.Addr == owner {
+			continue
+		}
+		v, err := n.Group.Get(key)
+		a.Nil(err)
+		a.Equal("value of Tom", v.String())
+		v, err = n.Group.Get("Jack")
+		a.Nil(err)
+		a.Equal("set", v.String())
+	}
+	a.Equal(1, src.count(key))
+	a.Equal(0, src.count("Jack"))
+}
+
+func TestCluster_CloseLeaksNoGoroutine(t *testing.T) {
+	a := assert.New(t)
+	before := runtime.NumGoroutine()
+	src := newSource()
+	c := NewCluster("scores", 2<<10, src, geek.HotKeyCache(1<<10, time.Minute, 1), geek.NotFoundCache(1<<10, time.Minute))
+	a.Nil(c.Start(3))
+	// the caches are created lazily with their expiry goroutines
+	for _, n := range c.Nodes() {
+		for _, key := range keys(10) {
+			_, err := n.Group.Get(key)
+			a.Nil(err)
+		}
+	}
+	c.Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.LessOrEqual(runtime.NumGoroutine(), before)
+}
@@ -0,0 +1,158 @@
+package geek
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	c "github.com/Makonike/geek-cache/geek/cache"
+)
+
+// HandoffEntry is an entry sent to its new owner after the ring changed
+type HandoffEntry struct {
+	Key            string
+	Value          ByteView
+	ExpirationTime time.Time // never expire if it's zero
+}
+
+// handoffBatchSize is the number of entries sent by each Handoff call at most,
+// so that each call is done within the timeout of the client
+const handoffBatchSize = 256
+
+// previousRing is the ring before the latest change, it's asked for the keys missed until the deadline
+type previousRing struct {
+	owner    func(key string) string
+	deadline time.Time
+}
+
+// Handoff keeps the keys warm when the ring changes, e.g. a node is added for scaling out:
+// the entries which are owned by other peers now are sent to them and dropped locally,
+// and for fallback after the change, the keys missed are asked from their previous owners before the Getter.
+// It works with the PeerPicker which implements RingWatcher and PeerLister, and the peers implementing HandoffPeer,
+// e.g. *ClientPicker and *Client
+func Handoff(fallback time.Duration) GroupOptions {
+	return func(g *Group) {
+		g.handoff = true
+		g.handoffFallback = fallback
+	}
+}
+
+// onRingChange is called by the RingWatcher after the ring changed
+func (g *Group) onRingChange(previousOwner func(key string) string) {
+	if g.isClosed() {
+		return
+	}
+	if g.handoffFallback > 0 {
+		g.previous.Store(&previousRing{owner: previousOwner, deadline: time.Now().Add(g.handoffFallback)})
+	}
+	g.handOff(context.Background())
+}
+
+// handOff sends the entries not owned by self any more to their new owners, then drops them.
+// Only the keys moved are collected from the cache, their values are read again batch by batch when they're sent
+func (g *Group) handOff(ctx context.Context) {
+	if g.mainCache.cacheBytes <= 0 {
+		return
+	}
+	moved := make(map[HandoffPeer][]string)
+	g.mainCache.storeLazyLoadIfNeed().Range(func(key string, _ c.Value, _ time.Time) bool {
+		if g.isReplica(key) {
+			// self still holds a replica of it
+			return true
+		}
+		if peer, ok, isSelf := g.peers.PickPeer(key); ok && !isSelf {
+			if hp, ok := peer.(HandoffPeer); ok {
+				moved[hp] = append(moved[hp], key)
+			}
+		}
+		return true
+	})
+	for peer, keys := range moved {
+		g.handOffTo(ctx, peer, keys)
+	}
+}
+
+// handOffTo sends the entries of keys to peer by batches of handoffBatchSize, each of them is dropped once it's sent.
+// It stops at the first batch failed, the entries not sent are kept
+func (g *Group) handOffTo(ctx context.Context, peer HandoffPeer, keys []string) {
+	sent, accepted := 0, 0
+	for len(keys) > 0 {
+		n := handoffBatchSize
+		if n > len(keys) {
+			n = len(keys)
+		}
+		entries := make([]HandoffEntry, 0, n)
+		for _, key := range keys[:n] {
+			// deleted or expired since collected
+			if v, expirationTime, ok := g.peekLocally(key); ok {
+				entries = append(entries, HandoffEntry{Key: key, Value: v, ExpirationTime: expirationTime})
+			}
+		}
+		keys = keys[n:]
+		if len(entries) == 0 {
+			continue
+		}
+		kept, err := peer.Handoff(ctx, g.name, entries)
+		if err != nil {
+			g.logger.Warn("failed to hand off", "group", g.name, "sent", sent, "kept", len(entries)+len(keys), "err", err)
+			break
+		}
+		sent += len(entries)
+		accepted += kept
+		// self is not the owner any more, the copies would be stale
+		for _, e := range entries {
+			g.mainCache.delete(e.Key)
+		}
+	}
+	if sent > 0 {
+		g.logger.Info("hand off", "group", g.name, "keys", sent, "accepted", accepted)
+	}
+}
+
+// acceptHandoff keeps the entry from the previous owner, unless it's expired or loaded already
+func (g *Group) acceptHandoff(key string, value []byte, expirationTime time.Time) bool {
+	if g.isClosed() {
+		return false
+	}
+	if !expirationTime.IsZero() && expirationTime.Before(time.Now()) {
+		return false
+	}
+	if _, _, ok := g.peekLocally(key); ok {
+		return false
+	}
+	g.populateCache(key, ByteView{cloneBytes(value)}, expirationTime)
+	return true
+}
+
+// peekLocally returns the value in the main cache without calling the Getter
+func (g *Group) peekLocally(key string) (ByteView, time.Time, bool) {
+	if g.mainCache.cacheBytes <= 0 {
+		return ByteView{}, time.Time{}, false
+	}
+	v, expirationTime, ok := g.mainCache.storeLazyLoadIfNeed().Peek(key)
+	if !ok {
+		return ByteView{}, time.Time{}, false
+	}
+	return v.(ByteView), expirationTime, true
+}
+
+// getFromPreviousOwner asks the previous owner of the key for its copy, within the fallback after the ring changed
+func (g *Group) getFromPreviousOwner(ctx context.Context, key string) (ByteView, bool) {
+	previous, _ := g.previous.Load().(*previousRing)
+	if previous == nil || time.Now().After(previous.deadline) {
+		return ByteView{}, false
+	}
+	// self is not in Peers
+	peer, ok := g.listPeers()[previous.owner(key)].(HandoffPeer)
+	if !ok {
+		return ByteView{}, false
+	}
+	bytes, expirationTime, err := peer.Peek(ctx, g.name, key)
+	if err != nil {
+		return ByteView{}, false
+	}
+	atomic.AddInt64(&g.stats.peerLoads, 1)
+	bw := ByteView{cloneBytes(bytes)}
+	g.populateCache(key, bw, expirationTime)
+	return bw, true
+}
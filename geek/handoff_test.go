@@ -0,0 +1,43 @@
+package geek
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_HandOff(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("handoff", 2<<20, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}), PrivateForTesting())
+	picker := &fakePicker{peers: map[byte]*fakePeer{
+		'a': {name: "A"},
+		'c': {name: "C", fail: true},
+	}}
+	g.RegisterPeers(picker)
+	n := 2*handoffBatchSize + 10
+	for i := 0; i < n; i++ {
+		g.populateCache(fmt.Sprintf("a%d", i), ByteView{[]byte("v")}, time.Time{})
+	}
+	g.populateCache("c1", ByteView{[]byte("v")}, time.Time{})
+	g.populateCache("s1", ByteView{[]byte("v")}, time.Time{})
+
+	g.handOff(context.Background())
+	// sent by batches, and dropped once sent
+	batches := picker.peers['a'].handoffs
+	a.Equal(3, len(batches))
+	a.Equal(handoffBatchSize, len(batches[0]))
+	a.Equal(10, len(batches[2]))
+	_, _, ok := g.peekLocally("a1")
+	a.False(ok)
+	// kept if the handoff failed
+	_, _, ok = g.peekLocally("c1")
+	a.True(ok)
+	_, _, ok = g.peekLocally("s1")
+	a.True(ok)
+}
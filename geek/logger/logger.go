@@ -0,0 +1,91 @@
+// Package logger is the leveled logger of geek-cache.
+// The arguments after msg are key-value pairs like log/slog, so *slog.Logger is a Logger as it is.
+package logger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type Level int
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+func (l Level) String() string {
+	switch l {
+	case DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARN:
+		return "WARN"
+	case ERROR:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Logger logs msg with the key-value pairs in args
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// stdLogger writes to a *log.Logger, the messages below level are dropped
+type stdLogger struct {
+	l     *log.Logger
+	level Level
+}
+
+// New returns a Logger writing to l, the messages below level are dropped
+func New(l *log.Logger, level Level) Logger {
+	return &stdLogger{l: l, level: level}
+}
+
+// Default writes the messages of INFO and above to the standard logger of package log
+func Default() Logger {
+	return New(log.Default(), INFO)
+}
+
+func (s *stdLogger) Debug(msg string, args ...interface{}) { s.log(DEBUG, msg, args) }
+func (s *stdLogger) Info(msg string, args ...interface{})  { s.log(INFO, msg, args) }
+func (s *stdLogger) Warn(msg string, args ...interface{})  { s.log(WARN, msg, args) }
+func (s *stdLogger) Error(msg string, args ...interface{}) { s.log(ERROR, msg, args) }
+
+// log formats like: [INFO] register service success addr=127.0.0.1:8001
+func (s *stdLogger) log(level Level, msg string, args []interface{}) {
+	if level < s.level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(level.String())
+	b.WriteString("] ")
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&b, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	_ = s.l.Output(3, b.String())
+}
+
+// Discard drops all messages
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debug(string, ...interface{}) {}
+func (discard) Info(string, ...interface{})  {}
+func (discard) Warn(string, ...interface{})  {}
+func (discard) Error(string, ...interface{}) {}
@@ -0,0 +1,22 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdLogger(t *testing.T) {
+	a := assert.New(t)
+	var buf bytes.Buffer
+	l := New(log.New(&buf, "", 0), INFO)
+	l.Debug("hit", "key", "Tom")
+	a.Empty(buf.String())
+	l.Info("register service success", "addr", "127.0.0.1:8001")
+	a.Equal("[INFO] register service success addr=127.0.0.1:8001\n", buf.String())
+	buf.Reset()
+	l.Error("failed", "err")
+	a.Equal("[ERROR] failed !BADKEY=err\n", buf.String())
+}
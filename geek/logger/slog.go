@@ -0,0 +1,15 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"log/slog"
+)
+
+// Slog returns a Logger writing to h, it's the same as slog.New(h)
+func Slog(h slog.Handler) Logger {
+	return slog.New(h)
+}
+
+// *slog.Logger is a Logger
+var _ Logger = (*slog.Logger)(nil)
@@ -0,0 +1,28 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlog(t *testing.T) {
+	a := assert.New(t)
+	var buf bytes.Buffer
+	l := Slog(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	l.Debug("hit", "key", "Tom")
+	a.Empty(buf.String())
+	l.Warn("failed to get from peer", "peer", "127.0.0.1:8002")
+	a.Equal("level=WARN msg=\"failed to get from peer\" peer=127.0.0.1:8002\n", buf.String())
+}
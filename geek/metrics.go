@@ -0,0 +1,266 @@
+package geek
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the statistics of a group, like the Stats of groupcache
+type Stats struct {
+	Gets          int64 // Get calls, each key of GetMany is counted
+	CacheHits     int64 // hits of the main cache or the hot cache
+	PeerLoads     int64 // values fetched from peers, or not found by them
+	PeerErrors    int64 // failed calls to peers, the keys are loaded locally instead
+	LocalLoads    int64 // values loaded by the Getter
+	LocalLoadErrs int64 // keys not found by the Getter, or the Getter was canceled
+	Dedups        int64 // loads which waited for the in-flight load of the same key instead of loading again
+	Misroutes     int64 // calls forwarded by peers for the keys which self doesn't own, the rings disagree
+	MainCache     CacheStats
+	HotCache      CacheStats
+	NegativeCache CacheStats
+}
+
+// groupStats are the counters of a group, counted by atomic
+type groupStats struct {
+	gets          int64
+	cacheHits     int64
+	peerLoads     int64
+	peerErrors    int64
+	localLoads    int64
+	localLoadErrs int64
+	dedups        int64
+	misroutes     int64
+}
+
+// Stats returns the statistics of the group
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:          atomic.LoadInt64(&g.stats.gets),
+		CacheHits:     atomic.LoadInt64(&g.stats.cacheHits),
+		PeerLoads:     atomic.LoadInt64(&g.stats.peerLoads),
+		PeerErrors:    atomic.LoadInt64(&g.stats.peerErrors),
+		LocalLoads:    atomic.LoadInt64(&g.stats.localLoads),
+		LocalLoadErrs: atomic.LoadInt64(&g.stats.localLoadErrs),
+		Dedups:        atomic.LoadInt64(&g.stats.dedups),
+		Misroutes:     atomic.LoadInt64(&g.stats.misroutes),
+		MainCache:     g.mainCache.stats(),
+		HotCache:      g.hotCache.stats(),
+		NegativeCache: g.negCache.stats(),
+	}
+}
+
+// the upper bounds of the latency buckets in seconds, the same as the default of prometheus
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// the methods of the rpc, keyed by which the latency is observed
+var rpcMethods = []string{"get", "delete", "set", "setreplica", "mget", "mdelete", "invalidate", "handoff", "peek"}
+
+// LatencyStats is a histogram of latency
+type LatencyStats struct {
+	Buckets []float64 // the upper bounds in seconds
+	Counts  []int64   // Counts[i] is the number of calls not slower than Buckets[i]
+	Count   int64     // the number of calls
+	Sum     float64   // the total latency in seconds
+}
+
+// RPCStats are the latency of the rpc calls, keyed by the method, e.g. "get"
+type RPCStats map[string]LatencyStats
+
+// histogram counts the latency by atomic
+type histogram struct {
+	counts []int64 // not cumulative, the last one is +Inf
+	sum    int64   // in nanoseconds
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(latencyBuckets, d.Seconds())
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *histogram) stats() LatencyStats {
+	stats := LatencyStats{
+		Buckets: latencyBuckets,
+		Counts:  make([]int64, len(latencyBuckets)),
+		Sum:     time.Duration(atomic.LoadInt64(&h.sum)).Seconds(),
+	}
+	for i := range h.counts {
+		stats.Count += atomic.LoadInt64(&h.counts[i])
+		if i < len(latencyBuckets) {
+			stats.Counts[i] = stats.Count
+		}
+	}
+	return stats
+}
+
+// rpcHistograms are the histograms of all methods, created at once so that they're read without lock
+type rpcHistograms map[string]*histogram
+
+func newRPCHistograms() rpcHistograms {
+	hs := make(rpcHistograms, len(rpcMethods))
+	for _, method := range rpcMethods {
+		hs[method] = newHistogram()
+	}
+	return hs
+}
+
+// observe drops the methods not in rpcMethods, the map is never written after it's created
+func (hs rpcHistograms) observe(method string, start time.Time) {
+	if h, ok := hs[method]; ok {
+		h.observe(time.Since(start))
+	}
+}
+
+func (hs rpcHistograms) stats() RPCStats {
+	stats := make(RPCStats, len(hs))
+	for method, h := range hs {
+		stats[method] = h.stats()
+	}
+	return stats
+}
+
+// Collector provides metrics to MetricsHandler, it's implemented by *Group, *Server and *ClientPicker
+type Collector interface {
+	collect(m *metricSet)
+}
+
+// MetricsHandler serves the metrics of the collectors in the Prometheus text format,
+// e.g. http.Handle("/metrics", geek.MetricsHandler(group, server, picker))
+func MetricsHandler(collectors ...Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		m := &metricSet{families: make(map[string]*metricFamily)}
+		for _, collector := range collectors {
+			collector.collect(m)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w)
+	})
+}
+
+func (g *Group) collect(m *metricSet) {
+	stats := g.Stats()
+	labels := []string{"group", g.name}
+	m.add("geek_cache_group_gets_total", "counter", "Get calls of the group.", labels, float64(stats.Gets))
+	m.add("geek_cache_group_cache_hits_total", "counter", "Hits of the main cache or the hot cache.", labels, float64(stats.CacheHits))
+	m.add("geek_cache_group_peer_loads_total", "counter", "Values fetched from peers.", labels, float64(stats.PeerLoads))
+	m.add("geek_cache_group_peer_errors_total", "counter", "Failed calls to peers.", labels, float64(stats.PeerErrors))
+	m.add("geek_cache_group_local_loads_total", "counter", "Values loaded by the Getter.", labels, float64(stats.LocalLoads))
+	m.add("geek_cache_group_local_load_errors_total", "counter", "Keys not found by the Getter.", labels, float64(stats.LocalLoadErrs))
+	m.add("geek_cache_group_dedups_total", "counter", "Loads deduplicated by singleflight.", labels, float64(stats.Dedups))
+	m.add("geek_cache_group_misroutes_total", "counter", "Calls forwarded by peers for the keys not owned by self.", labels, float64(stats.Misroutes))
+	for _, c := range []struct {
+		name  string
+		stats CacheStats
+	}{{"main", stats.MainCache}, {"hot", stats.HotCache}, {"negative", stats.NegativeCache}} {
+		labels := []string{"group", g.name, "cache", c.name}
+		m.add("geek_cache_cache_gets_total", "counter", "Lookups of the cache.", labels, float64(c.stats.Gets))
+		m.add("geek_cache_cache_hits_total", "counter", "Hits of the cache.", labels, float64(c.stats.Hits))
+		m.add("geek_cache_cache_evictions_total", "counter", "Keys evicted from the cache.",
+			append(labels, "reason", "capacity"), float64(c.stats.Evictions))
+		m.add("geek_cache_cache_evictions_total", "counter", "Keys evicted from the cache.",
+			append(labels, "reason", "expired"), float64(c.stats.Expirations))
+		m.add("geek_cache_cache_bytes", "gauge", "Memory in use of the cache.", labels, float64(c.stats.Bytes))
+		m.add("geek_cache_cache_items", "gauge", "Number of keys in the cache.", labels, float64(c.stats.Items))
+	}
+}
+
+func (s *Server) collect(m *metricSet) {
+	m.addLatency("geek_cache_server_rpc_duration_seconds", "Latency of the rpc calls served.",
+		[]string{"self", s.self}, s.Stats())
+}
+
+func (p *ClientPicker) collect(m *metricSet) {
+	stats := p.Stats()
+	addrs := make([]string, 0, len(stats))
+	for addr := range stats {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		m.addLatency("geek_cache_client_rpc_duration_seconds", "Latency of the rpc calls to peers.",
+			[]string{"self", p.self, "peer", addr}, stats[addr])
+	}
+}
+
+// metricSet groups the samples by metric name, as each metric is described once in the text format
+type metricSet struct {
+	names    []string // in the order of adding
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	help, typ string
+	samples   []string
+}
+
+// add a sample, labels are pairs of name and value
+func (m *metricSet) add(name, typ, help string, labels []string, value float64) {
+	m.addSample(name, typ, help, name, labels, value)
+}
+
+func (m *metricSet) addSample(family, typ, help, name string, labels []string, value float64) {
+	f, ok := m.families[family]
+	if !ok {
+		f = &metricFamily{help: help, typ: typ}
+		m.families[family] = f
+		m.names = append(m.names, family)
+	}
+	f.samples = append(f.samples, name+formatLabels(labels)+" "+strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (m *metricSet) addLatency(name, help string, labels []string, stats RPCStats) {
+	methods := make([]string, 0, len(stats))
+	for method := range stats {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		h := stats[method]
+		labels := append(labels[:len(labels):len(labels)], "method", method)
+		for i, le := range h.Buckets {
+			m.addSample(name, "histogram", help, name+"_bucket",
+				append(labels[:len(labels):len(labels)], "le", strconv.FormatFloat(le, 'g', -1, 64)), float64(h.Counts[i]))
+		}
+		m.addSample(name, "histogram", help, name+"_bucket", append(labels, "le", "+Inf"), float64(h.Count))
+		m.addSample(name, "histogram", help, name+"_sum", labels, h.Sum)
+		m.addSample(name, "histogram", help, name+"_count", labels, float64(h.Count))
+	}
+}
+
+func (m *metricSet) write(w io.Writer) {
+	for _, name := range m.names {
+		f := m.families[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.typ)
+		for _, sample := range f.samples {
+			fmt.Fprintln(w, sample)
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
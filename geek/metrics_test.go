@@ -0,0 +1,112 @@
+package geek
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Stats(t *testing.T) {
+	a := assert.New(t)
+	release := make(chan struct{})
+	g := NewGroup("stats", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			if key == "unknown" {
+				return nil, false, time.Time{}
+			}
+			<-release
+			return []byte("db" + key), true, time.Time{}
+		}), PrivateForTesting())
+
+	// concurrent gets of the same key share one load
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = g.Get("Tom")
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	_, _ = g.Get("Tom")
+	_, _ = g.Get("unknown")
+
+	stats := g.Stats()
+	a.Equal(int64(5), stats.Gets)
+	a.Equal(int64(1), stats.CacheHits)
+	a.Equal(int64(1), stats.LocalLoads)
+	a.Equal(int64(1), stats.LocalLoadErrs)
+	a.Equal(int64(2), stats.Dedups)
+	a.Equal(int64(1), stats.MainCache.Items)
+	a.Equal(int64(len("Tom")+len("dbTom")), stats.MainCache.Bytes)
+
+	// values from peers
+	g.RegisterPeers(&fakePicker{peers: map[byte]*fakePeer{
+		'a': {name: "A"},
+		'c': {name: "C", fail: true},
+	}})
+	g.GetMany([]string{"a1", "c1"})
+	stats = g.Stats()
+	a.Equal(int64(1), stats.PeerLoads)
+	a.Equal(int64(1), stats.PeerErrors)
+}
+
+func TestGroup_StatsEvictions(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("evictions", 20, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("1234567890"), true, time.Time{}
+		}), PrivateForTesting())
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = g.Get(key)
+	}
+	_, _ = g.Set("d", []byte("1"), time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	// loaded again after expired, and evicts c
+	_, _ = g.Get("d")
+	stats := g.Stats().MainCache
+	a.Equal(int64(3), stats.Evictions)
+	a.Equal(int64(1), stats.Expirations)
+}
+
+func TestRPCHistograms_UnknownMethod(t *testing.T) {
+	a := assert.New(t)
+	hs := newRPCHistograms()
+	a.NotPanics(func() { hs.observe("unknown", time.Now()) })
+	hs.observe("get", time.Now())
+	stats := hs.stats()
+	a.Equal(int64(1), stats["get"].Count)
+	_, ok := stats["unknown"]
+	a.False(ok)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	a := assert.New(t)
+	g := NewGroup("metrics", 2<<10, GetterFunc(
+		func(key string) ([]byte, bool, time.Time) {
+			return []byte("db" + key), true, time.Time{}
+		}), PrivateForTesting())
+	_, _ = g.Get("Tom")
+	s, err := NewServer("", ServerGroupsForTesting(func(string) *Group { return g }))
+	a.Nil(err)
+	_, _ = s.Invalidate(context.Background(), nil)
+
+	w := httptest.NewRecorder()
+	MetricsHandler(g, s).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	a.Contains(body, "# TYPE geek_cache_group_gets_total counter\n")
+	a.Contains(body, `geek_cache_group_local_loads_total{group="metrics"} 1`)
+	a.Contains(body, `geek_cache_cache_items{group="metrics",cache="main"} 1`)
+	a.Contains(body, `geek_cache_cache_evictions_total{group="metrics",cache="main",reason="capacity"} 0`)
+	a.Contains(body, `geek_cache_server_rpc_duration_seconds_bucket{self="127.0.0.1:7654",method="invalidate",le="+Inf"} 1`)
+	a.Contains(body, `geek_cache_server_rpc_duration_seconds_count{self="127.0.0.1:7654",method="get"} 0`)
+	// each metric is described once
+	a.Equal(1, strings.Count(body, "# TYPE geek_cache_cache_evictions_total "))
+}
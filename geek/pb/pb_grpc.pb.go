@@ -24,6 +24,12 @@ const _ = grpc.SupportPackageIsVersion7
 type GroupCacheClient interface {
 	Get(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForGet, error)
 	Delete(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForDelete, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*ResponseForSet, error)
+	MGet(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*ResponseForMGet, error)
+	MDelete(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*ResponseForMDelete, error)
+	Invalidate(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForInvalidate, error)
+	Handoff(ctx context.Context, opts ...grpc.CallOption) (GroupCache_HandoffClient, error)
+	Peek(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForPeek, error)
 }
 
 type groupCacheClient struct {
@@ -52,12 +58,97 @@ func (c *groupCacheClient) Delete(ctx context.Context, in *Request, opts ...grpc
 	return out, nil
 }
 
+func (c *groupCacheClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*ResponseForSet, error) {
+	out := new(ResponseForSet)
+	err := c.cc.Invoke(ctx, "/pb.GroupCache/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) MGet(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*ResponseForMGet, error) {
+	out := new(ResponseForMGet)
+	err := c.cc.Invoke(ctx, "/pb.GroupCache/MGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) MDelete(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*ResponseForMDelete, error) {
+	out := new(ResponseForMDelete)
+	err := c.cc.Invoke(ctx, "/pb.GroupCache/MDelete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) Invalidate(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForInvalidate, error) {
+	out := new(ResponseForInvalidate)
+	err := c.cc.Invoke(ctx, "/pb.GroupCache/Invalidate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) Handoff(ctx context.Context, opts ...grpc.CallOption) (GroupCache_HandoffClient, error) {
+	stream, err := c.cc.NewStream(ctx, &GroupCache_ServiceDesc.Streams[0], "/pb.GroupCache/Handoff", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &groupCacheHandoffClient{stream}
+	return x, nil
+}
+
+type GroupCache_HandoffClient interface {
+	Send(*HandoffEntry) error
+	CloseAndRecv() (*ResponseForHandoff, error)
+	grpc.ClientStream
+}
+
+type groupCacheHandoffClient struct {
+	grpc.ClientStream
+}
+
+func (x *groupCacheHandoffClient) Send(m *HandoffEntry) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *groupCacheHandoffClient) CloseAndRecv() (*ResponseForHandoff, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ResponseForHandoff)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *groupCacheClient) Peek(ctx context.Context, in *Request, opts ...grpc.CallOption) (*ResponseForPeek, error) {
+	out := new(ResponseForPeek)
+	err := c.cc.Invoke(ctx, "/pb.GroupCache/Peek", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GroupCacheServer is the server API for GroupCache service.
 // All implementations must embed UnimplementedGroupCacheServer
 // for forward compatibility
 type GroupCacheServer interface {
 	Get(context.Context, *Request) (*ResponseForGet, error)
 	Delete(context.Context, *Request) (*ResponseForDelete, error)
+	Set(context.Context, *SetRequest) (*ResponseForSet, error)
+	MGet(context.Context, *BatchRequest) (*ResponseForMGet, error)
+	MDelete(context.Context, *BatchRequest) (*ResponseForMDelete, error)
+	Invalidate(context.Context, *Request) (*ResponseForInvalidate, error)
+	Handoff(GroupCache_HandoffServer) error
+	Peek(context.Context, *Request) (*ResponseForPeek, error)
 	mustEmbedUnimplementedGroupCacheServer()
 }
 
@@ -71,6 +162,24 @@ func (UnimplementedGroupCacheServer) Get(context.Context, *Request) (*ResponseFo
 func (UnimplementedGroupCacheServer) Delete(context.Context, *Request) (*ResponseForDelete, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
 }
+func (UnimplementedGroupCacheServer) Set(context.Context, *SetRequest) (*ResponseForSet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedGroupCacheServer) MGet(context.Context, *BatchRequest) (*ResponseForMGet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MGet not implemented")
+}
+func (UnimplementedGroupCacheServer) MDelete(context.Context, *BatchRequest) (*ResponseForMDelete, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MDelete not implemented")
+}
+func (UnimplementedGroupCacheServer) Invalidate(context.Context, *Request) (*ResponseForInvalidate, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invalidate not implemented")
+}
+func (UnimplementedGroupCacheServer) Handoff(GroupCache_HandoffServer) error {
+	return status.Errorf(codes.Unimplemented, "method Handoff not implemented")
+}
+func (UnimplementedGroupCacheServer) Peek(context.Context, *Request) (*ResponseForPeek, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Peek not implemented")
+}
 func (UnimplementedGroupCacheServer) mustEmbedUnimplementedGroupCacheServer() {}
 
 // UnsafeGroupCacheServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +229,122 @@ func _GroupCache_Delete_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GroupCache_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.GroupCache/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_MGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).MGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.GroupCache/MGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).MGet(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_MDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).MDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.GroupCache/MDelete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).MDelete(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_Invalidate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).Invalidate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.GroupCache/Invalidate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).Invalidate(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_Handoff_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GroupCacheServer).Handoff(&groupCacheHandoffServer{stream})
+}
+
+type GroupCache_HandoffServer interface {
+	SendAndClose(*ResponseForHandoff) error
+	Recv() (*HandoffEntry, error)
+	grpc.ServerStream
+}
+
+type groupCacheHandoffServer struct {
+	grpc.ServerStream
+}
+
+func (x *groupCacheHandoffServer) SendAndClose(m *ResponseForHandoff) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *groupCacheHandoffServer) Recv() (*HandoffEntry, error) {
+	m := new(HandoffEntry)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _GroupCache_Peek_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).Peek(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.GroupCache/Peek",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).Peek(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // GroupCache_ServiceDesc is the grpc.ServiceDesc for GroupCache service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,7 +360,33 @@ var GroupCache_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _GroupCache_Delete_Handler,
 		},
+		{
+			MethodName: "Set",
+			Handler:    _GroupCache_Set_Handler,
+		},
+		{
+			MethodName: "MGet",
+			Handler:    _GroupCache_MGet_Handler,
+		},
+		{
+			MethodName: "MDelete",
+			Handler:    _GroupCache_MDelete_Handler,
+		},
+		{
+			MethodName: "Invalidate",
+			Handler:    _GroupCache_Invalidate_Handler,
+		},
+		{
+			MethodName: "Peek",
+			Handler:    _GroupCache_Peek_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Handoff",
+			Handler:       _GroupCache_Handoff_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb.proto",
 }
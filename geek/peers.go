@@ -2,15 +2,15 @@ package geek
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/Makonike/geek-cache/geek/consistenthash"
+	"github.com/Makonike/geek-cache/geek/logger"
 	registry "github.com/Makonike/geek-cache/geek/registry"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // PeerPicker must be implemented to locate the peer that owns a specific key
@@ -20,16 +20,69 @@ type PeerPicker interface {
 
 // PeerGetter must be implemented by a peer
 type PeerGetter interface {
-	Get(group string, key string) ([]byte, error)
-	Delete(group string, key string) (bool, error)
+	Get(ctx context.Context, group string, key string) ([]byte, error)
+	Delete(ctx context.Context, group string, key string) (bool, error)
+	Set(ctx context.Context, group string, key string, value []byte, ttl time.Duration) (bool, error)
+	MGet(ctx context.Context, group string, keys []string) ([]GetResult, error)
+	MDelete(ctx context.Context, group string, keys []string) ([]DeleteResult, error)
+	Invalidate(ctx context.Context, group string, key string) error
 }
 
+// ReplicaPeer is implemented by the PeerGetter which keeps replicas for Replication, e.g. *Client,
+// the other peers are skipped when the replicas are written
+type ReplicaPeer interface {
+	// SetReplica stores the key-value on the peer itself as a replica, it's not routed to the owner
+	SetReplica(ctx context.Context, group string, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// HandoffPeer is implemented by the PeerGetter which takes part in Handoff, e.g. *Client,
+// the entries are not handed off to the other peers, and they're never asked as the previous owners
+type HandoffPeer interface {
+	// Handoff sends the entries which the peer owns now, and returns the number of entries it kept
+	Handoff(ctx context.Context, group string, entries []HandoffEntry) (int, error)
+	// Peek returns the value in the cache of the peer without calling the Getter, ErrNotFound if it's not cached
+	Peek(ctx context.Context, group string, key string) ([]byte, time.Time, error)
+}
+
+// PeerLister is implemented by the PeerPicker which knows all peers, e.g. *ClientPicker,
+// the invalidations of Delete are broadcast to them
+type PeerLister interface {
+	// Peers returns all peers except self, keyed by address
+	Peers() map[string]PeerGetter
+}
+
+// RingWatcher is implemented by the PeerPicker which reports the changes of the ring, e.g. *ClientPicker
+type RingWatcher interface {
+	// WatchRing calls fn after the peers are changed, previousOwner returns the owner of a key before the change
+	// the returned cancel stops calling fn
+	WatchRing(fn func(previousOwner func(key string) string)) (cancel func())
+}
+
+// ReplicaPicker is implemented by the PeerPicker which places a key on several peers, e.g. *ClientPicker
+type ReplicaPicker interface {
+	// PickReplicas returns at most n distinct peers holding the key, the first one is the owner.
+	// The PeerGetter of self is nil
+	PickReplicas(key string, n int) []PeerGetter
+}
+
+const watchRetryInterval = time.Second
+
 type ClientPicker struct {
 	self        string // self ip
 	serviceName string
 	mu          sync.RWMutex        // guards
 	consHash    *consistenthash.Map // stores the list of peers, selected by specific key
 	clients     map[string]*Client  // keyed by e.g. "10.0.0.2:8009"
+	clientOpts  []ClientOptions     // options of the clients to peers
+	discovery   registry.Discovery  // finds the peers, etcd by default
+	ctx         context.Context     // done when the picker is closed
+	cancel      context.CancelFunc
+	logger      logger.Logger
+	onError     func(err error)                                     // called when the peers can't be updated
+	watchers    map[int]func(previousOwner func(key string) string) // keyed by the id of WatchRing
+	nextWatcher int
+	previous    *consistenthash.Map // the ring before the latest change, nil if it's never changed
+	synced      bool                // the peers have been updated by the discovery
 }
 
 func NewClientPicker(self string, opts ...PickerOptions) *ClientPicker {
@@ -37,82 +90,20 @@ func NewClientPicker(self string, opts ...PickerOptions) *ClientPicker {
 		self:        self,
 		serviceName: defaultServiceName,
 		clients:     make(map[string]*Client),
+		watchers:    make(map[int]func(previousOwner func(key string) string)),
 		mu:          sync.RWMutex{},
 		consHash:    consistenthash.New(),
+		discovery:   registry.NewEtcdDiscovery(nil),
+		logger:      logger.Default(),
 	}
 	picker.mu.Lock()
 	for _, opt := range opts {
 		opt(&picker)
 	}
 	picker.mu.Unlock()
-	// 增量更新
-	// TODO: watch closed
+	picker.ctx, picker.cancel = context.WithCancel(context.Background())
 	picker.set(picker.self)
-	go func() {
-		cli, err := clientv3.New(*registry.GlobalClientConfig)
-		if err != nil {
-			log.Fatal(err)
-			return
-		}
-		defer cli.Close()
-		// watcher will watch for changes of the service node
-		watcher := clientv3.NewWatcher(cli)
-		watchCh := watcher.Watch(context.Background(), picker.serviceName, clientv3.WithPrefix())
-		for {
-			a := <-watchCh
-			go func() {
-				picker.mu.Lock()
-				defer picker.mu.Unlock()
-				for _, x := range a.Events {
-					// x: geek-cache/127.0.0.1:8004
-					key := string(x.Kv.Key)
-					idx := strings.Index(key, picker.serviceName)
-					addr := key[idx+len(picker.serviceName)+1:]
-					if addr == picker.self {
-						continue
-					}
-					if x.IsCreate() {
-						if _, ok := picker.clients[addr]; !ok {
-							picker.set(addr)
-						}
-					} else if x.Type == clientv3.EventTypeDelete {
-						if _, ok := picker.clients[addr]; ok {
-							picker.remove(addr)
-						}
-					}
-				}
-			}()
-		}
-	}()
-	// 全量更新
-	go func() {
-		picker.mu.Lock()
-		cli, err := clientv3.New(*registry.GlobalClientConfig)
-		if err != nil {
-			log.Fatal(err)
-			return
-		}
-		defer cli.Close()
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-		resp, err := cli.Get(ctx, picker.serviceName, clientv3.WithPrefix())
-		if err != nil {
-			log.Panic("[Event] full copy request failed")
-		}
-		kvs := resp.OpResponse().Get().Kvs
-
-		defer picker.mu.Unlock()
-		for _, kv := range kvs {
-			key := string(kv.Key)
-			idx := strings.Index(key, picker.serviceName)
-			addr := key[idx+len(picker.serviceName)+1:]
-
-			if _, ok := picker.clients[addr]; !ok {
-				picker.set(addr)
-			}
-
-		}
-	}()
+	go picker.watch()
 	return &picker
 }
 
@@ -124,19 +115,185 @@ func PickerServiceName(serviceName string) PickerOptions {
 	}
 }
 
+// PickerDiscovery sets where the peers are found, etcd by default,
+// it should be the same as the ServerDiscovery of the peers
+func PickerDiscovery(d registry.Discovery) PickerOptions {
+	return func(picker *ClientPicker) {
+		picker.discovery = d
+	}
+}
+
+// PickerLogger sets the logger of the picker, logger.Default() by default
+func PickerLogger(l logger.Logger) PickerOptions {
+	return func(picker *ClientPicker) {
+		picker.logger = l
+	}
+}
+
+// PickerErrorHandler sets the callback when the peers can't be updated,
+// e.g. the discovery is unreachable or a peer can't be dialed.
+// The picker keeps retrying, it's used for health checks and alerts.
+func PickerErrorHandler(fn func(err error)) PickerOptions {
+	return func(picker *ClientPicker) {
+		picker.onError = fn
+	}
+}
+
+// PickerClientOptions sets the options of the clients to peers, e.g. pool size, timeout and keepalive
+func PickerClientOptions(opts ...ClientOptions) PickerOptions {
+	return func(picker *ClientPicker) {
+		picker.clientOpts = append(picker.clientOpts, opts...)
+	}
+}
+
+// PickerTLS dials the peers over TLS, it's a shortcut of PickerClientOptions(ClientTLS(config))
+func PickerTLS(config *tls.Config) PickerOptions {
+	return PickerClientOptions(ClientTLS(config))
+}
+
+// PickerAuth signs the calls to the peers by signer, it's a shortcut of PickerClientOptions(ClientAuth(signer))
+func PickerAuth(signer Signer) PickerOptions {
+	return PickerClientOptions(ClientAuth(signer))
+}
+
 func ConsHashOptions(opts ...consistenthash.ConsOptions) PickerOptions {
 	return func(picker *ClientPicker) {
 		picker.consHash = consistenthash.New(opts...)
 	}
 }
 
+// watch keeps the peers up to date with the discovery until Close
+func (p *ClientPicker) watch() {
+	for {
+		ch, err := p.discovery.Watch(p.ctx, p.serviceName)
+		if err != nil {
+			p.fail(fmt.Errorf("failed to watch peers: %v", err))
+		} else {
+			for addrs := range ch {
+				p.update(addrs)
+			}
+		}
+		// the watch is broken, e.g. etcd is restarted
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// Close stops watching the peers and closes the connections to them
+func (p *ClientPicker) Close() error {
+	p.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr := range p.clients {
+		if addr != p.self {
+			p.remove(addr)
+		}
+	}
+	return nil
+}
+
+// update adds the new peers and removes the peers which are gone, self is always kept
+func (p *ClientPicker) update(addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil {
+		// closed
+		return
+	}
+	previous := p.consHash.Clone()
+	changed := false
+	joining := !p.synced
+	p.synced = true
+	latest := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		latest[addr] = true
+		if _, ok := p.clients[addr]; !ok {
+			p.set(addr)
+			_, added := p.clients[addr]
+			changed = changed || added
+		}
+	}
+	for addr := range p.clients {
+		if !latest[addr] && addr != p.self {
+			p.remove(addr)
+			changed = true
+		}
+	}
+	if joining {
+		// self joins the ring, the others owned the keys before it
+		previous = p.consHash.Clone()
+		previous.Remove(p.self)
+	}
+	if changed {
+		p.previous = previous
+		for _, fn := range p.watchers {
+			go fn(previous.Get)
+		}
+	}
+}
+
+// WatchRing calls fn after the peers are changed, previousOwner returns the owner of a key before the change.
+// fn is called at once for the latest change if the ring has changed before, until cancel is called
+func (p *ClientPicker) WatchRing(fn func(previousOwner func(key string) string)) (cancel func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.nextWatcher
+	p.nextWatcher++
+	p.watchers[id] = fn
+	if p.previous != nil {
+		go fn(p.previous.Get)
+	}
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.watchers, id)
+	}
+}
+
+// Owner returns the address of the peer which owns the key, empty if there are no peers
+func (p *ClientPicker) Owner(key string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.consHash.Get(key)
+}
+
+// Members returns the sorted addresses of the peers in the ring, including self
+func (p *ClientPicker) Members() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	addrs := make([]string, 0, len(p.clients))
+	for addr := range p.clients {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
 func (p *ClientPicker) set(addr string) {
+	if addr == p.self {
+		// never request self by rpc
+		p.consHash.Add(addr)
+		p.clients[addr] = nil
+		return
+	}
+	// the peer is found by the discovery already, the client dials it directly
+	client, err := NewClient(addr, "", p.clientOpts...)
+	if err != nil {
+		p.fail(fmt.Errorf("failed to create client for %s: %v", addr, err))
+		return
+	}
 	p.consHash.Add(addr)
-	p.clients[addr] = NewClient(addr, p.serviceName)
+	p.clients[addr] = client
 }
 
 func (p *ClientPicker) remove(addr string) {
 	p.consHash.Remove(addr)
+	if client := p.clients[addr]; client != nil {
+		_ = client.Close()
+	}
 	delete(p.clients, addr)
 }
 
@@ -145,13 +302,66 @@ func (s *ClientPicker) PickPeer(key string) (PeerGetter, bool, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	if peer := s.consHash.Get(key); peer != "" {
-		s.Log("Pick peer %s", peer)
-		return s.clients[peer], true, peer == s.self
+		s.logger.Debug("pick peer", "self", s.self, "peer", peer)
+		if peer == s.self {
+			return nil, true, true
+		}
+		return s.clients[peer], true, false
 	}
 	return nil, false, false
 }
 
+// PickReplicas picks the n peers next to the key clockwise on the ring, the first one is the owner
+func (s *ClientPicker) PickReplicas(key string, n int) []PeerGetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	addrs := s.consHash.GetN(key, n)
+	peers := make([]PeerGetter, len(addrs))
+	for i, addr := range addrs {
+		if addr != s.self {
+			peers[i] = s.clients[addr]
+		}
+	}
+	return peers
+}
+
+// Peers returns all peers except self, keyed by address
+func (s *ClientPicker) Peers() map[string]PeerGetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	peers := make(map[string]PeerGetter, len(s.clients))
+	for addr, client := range s.clients {
+		if addr != s.self && client != nil {
+			peers[addr] = client
+		}
+	}
+	return peers
+}
+
+var _ PeerLister = (*ClientPicker)(nil)
+
+// Stats returns the latency of the rpc calls to each peer, keyed by address
+func (s *ClientPicker) Stats() map[string]RPCStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := make(map[string]RPCStats, len(s.clients))
+	for addr, client := range s.clients {
+		if addr != s.self && client != nil {
+			stats[addr] = client.Stats()
+		}
+	}
+	return stats
+}
+
 // Log info
 func (s *ClientPicker) Log(format string, path ...interface{}) {
-	log.Printf("[Server %s] %s", s.self, fmt.Sprintf(format, path...))
+	s.logger.Info(fmt.Sprintf(format, path...), "self", s.self)
+}
+
+// fail reports the error which the picker can't handle by itself
+func (p *ClientPicker) fail(err error) {
+	p.logger.Error(err.Error(), "self", p.self)
+	if p.onError != nil {
+		p.onError(err)
+	}
 }
@@ -0,0 +1,75 @@
+package geek
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Makonike/geek-cache/geek/consistenthash"
+	"github.com/Makonike/geek-cache/geek/logger"
+	"github.com/Makonike/geek-cache/geek/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientPicker_Update(t *testing.T) {
+	a := assert.New(t)
+	self := "127.0.0.1:8001"
+	// without watching, the updates are applied by hand
+	picker := &ClientPicker{
+		self:     self,
+		clients:  make(map[string]*Client),
+		consHash: consistenthash.New(),
+		ctx:      context.Background(),
+		logger:   logger.Discard,
+	}
+	picker.set(self)
+	picker.update([]string{self, "127.0.0.1:8002", "127.0.0.1:8003"})
+	a.Equal(2, len(picker.Peers()))
+	a.Contains(picker.Peers(), "127.0.0.1:8003")
+
+	// the peers gone are removed, self is always kept
+	picker.update([]string{"127.0.0.1:8002"})
+	peers := picker.Peers()
+	a.Equal(1, len(peers))
+	a.Contains(peers, "127.0.0.1:8002")
+	a.Contains(picker.clients, self)
+	for i := 0; i < 10; i++ {
+		_, ok, _ := picker.PickPeer(string(rune('a' + i)))
+		a.True(ok)
+	}
+}
+
+// brokenDiscovery can't be reached
+type brokenDiscovery struct {
+	*registry.StaticDiscovery
+}
+
+func (brokenDiscovery) Watch(ctx context.Context, service string) (<-chan []string, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func TestClientPicker_ErrorHandler(t *testing.T) {
+	a := assert.New(t)
+	errs := make(chan error, 1)
+	picker := NewClientPicker("127.0.0.1:8001",
+		PickerDiscovery(brokenDiscovery{registry.NewStaticDiscovery()}),
+		PickerLogger(logger.Discard),
+		PickerErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}))
+	defer picker.Close()
+	select {
+	case err := <-errs:
+		a.Contains(err.Error(), "connection refused")
+	case <-time.After(time.Second):
+		t.Fatal("the error is not reported")
+	}
+	// self is still in the ring
+	_, ok, isSelf := picker.PickPeer("Tom")
+	a.True(ok)
+	a.True(isSelf)
+}
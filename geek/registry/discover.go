@@ -4,19 +4,25 @@ import (
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/resolver"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 // EtcdDial request a server from grpc
-// Connection can be obtained by providing an etcd client and service name
-func EtcdDial(c *clientv3.Client, service, target string) (*grpc.ClientConn, error) {
+// Connection can be obtained by providing an etcd client and service name.
+// It's plaintext unless the credentials are set by opts, e.g. grpc.WithTransportCredentials(credentials.NewTLS(config)).
+// geek.Client doesn't use it, it dials the peers listed by Discovery directly;
+// it's kept for the callers which dial a service through the resolver of etcd by themselves
+func EtcdDial(c *clientv3.Client, service, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	etcdResolver, err := resolver.NewBuilder(c)
 	if err != nil {
 		return nil, err
 	}
 	return grpc.Dial(
 		"etcd:///"+service+"/"+target,
-		grpc.WithResolvers(etcdResolver),
-		grpc.WithInsecure(),
-		grpc.WithBlock(),
+		append([]grpc.DialOption{
+			grpc.WithResolvers(etcdResolver),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithBlock(),
+		}, opts...)...,
 	)
 }
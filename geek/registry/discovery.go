@@ -0,0 +1,29 @@
+package registry
+
+import (
+	"context"
+	"sort"
+)
+
+// Discovery registers the nodes of a service and finds them,
+// it decouples the cluster from etcd
+type Discovery interface {
+	// Register registers addr as a node of service,
+	// it blocks until ctx is done and deregisters addr before returning
+	Register(ctx context.Context, service, addr string) error
+	// List returns the addresses of the nodes of service
+	List(ctx context.Context, service string) ([]string, error)
+	// Watch sends the full list of the addresses every time the nodes of service change,
+	// the first list is sent at once, and the channel is closed when ctx is done
+	Watch(ctx context.Context, service string) (<-chan []string, error)
+}
+
+// members returns the sorted addresses of the set
+func members(set map[string]struct{}) []string {
+	addrs := make([]string, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
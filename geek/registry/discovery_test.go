@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestStaticDiscovery(t *testing.T) {
+	a := assert.New(t)
+	d := NewStaticDiscovery("127.0.0.1:8001", "127.0.0.1:8002")
+	addrs, err := d.List(context.Background(), "geek-cache")
+	a.Nil(err)
+	a.Equal([]string{"127.0.0.1:8001", "127.0.0.1:8002"}, addrs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := d.Watch(ctx, "geek-cache")
+	a.Nil(err)
+	a.Equal(addrs, <-ch)
+	cancel()
+	_, ok := <-ch
+	a.False(ok)
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	a := assert.New(t)
+	var mu sync.Mutex
+	records := []*net.SRV{
+		{Target: "node2.geek.local.", Port: 8002},
+		{Target: "node1.geek.local.", Port: 8001},
+	}
+	lookup := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if service != "geek-cache" || proto != "tcp" || name != "geek.local" {
+			return "", nil, fmt.Errorf("no such host")
+		}
+		return "_geek-cache._tcp.geek.local.", records, nil
+	}
+	d := NewDNSDiscovery("geek.local", DNSLookup(lookup), DNSInterval(10*time.Millisecond))
+
+	addrs, err := d.List(context.Background(), "geek-cache")
+	a.Nil(err)
+	a.Equal([]string{"node1.geek.local:8001", "node2.geek.local:8002"}, addrs)
+	_, err = d.List(context.Background(), "unknown")
+	a.NotNil(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := d.Watch(ctx, "geek-cache")
+	a.Nil(err)
+	a.Equal(addrs, <-ch)
+	// the list is sent again only when the records change
+	mu.Lock()
+	records = records[:1]
+	mu.Unlock()
+	select {
+	case addrs = <-ch:
+		a.Equal([]string{"node2.geek.local:8002"}, addrs)
+	case <-time.After(time.Second):
+		t.Fatal("the change of records is not sent")
+	}
+}
+
+func TestEtcdDiscovery_KeepRegistered(t *testing.T) {
+	a := assert.New(t)
+	var mu sync.Mutex
+	var states []RegistrationState
+	d := NewEtcdDiscovery(nil, EtcdBackoff(time.Millisecond, 4*time.Millisecond),
+		EtcdRegistrationHandler(func(state RegistrationState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, state)
+		}))
+
+	// the first lease is lost, then the registration fails once before it succeeds again
+	lost := make(chan struct{})
+	calls := 0
+	registered := make(chan struct{})
+	register := func() (<-chan struct{}, error) {
+		calls++
+		switch calls {
+		case 1:
+			return lost, nil
+		case 2:
+			return nil, fmt.Errorf("etcd unavailable")
+		default:
+			close(registered)
+			return make(chan struct{}), nil
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.keepRegistered(ctx, "geek-cache", "127.0.0.1:8001", register)
+	}()
+	close(lost)
+	<-registered
+	cancel()
+	a.Nil(<-done)
+	a.Equal(3, calls)
+	a.Equal([]RegistrationState{REGISTERED, REREGISTERING, REREGISTERING, REGISTERED, DEREGISTERED}, states)
+
+	// the error of the first registration is returned
+	err := d.keepRegistered(context.Background(), "geek-cache", "127.0.0.1:8001", func() (<-chan struct{}, error) {
+		return nil, fmt.Errorf("etcd unavailable")
+	})
+	a.NotNil(err)
+}
+
+func TestApplyEvents(t *testing.T) {
+	a := assert.New(t)
+	event := func(typ mvccpb.Event_EventType, addr string) *clientv3.Event {
+		return &clientv3.Event{Type: typ, Kv: &mvccpb.KeyValue{Key: []byte("geek-cache/" + addr)}}
+	}
+	set := map[string]struct{}{"127.0.0.1:8001": {}}
+	// the node restarted is kept, the events are applied in order
+	applyEvents(set, "geek-cache", []*clientv3.Event{
+		event(clientv3.EventTypeDelete, "127.0.0.1:8001"),
+		event(clientv3.EventTypePut, "127.0.0.1:8002"),
+		event(clientv3.EventTypePut, "127.0.0.1:8001"),
+		event(clientv3.EventTypeDelete, "127.0.0.1:8002"),
+	})
+	a.Equal([]string{"127.0.0.1:8001"}, members(set))
+}
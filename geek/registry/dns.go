@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const defaultDNSInterval = 30 * time.Second
+
+// DNSDiscovery finds the peers by the SRV records _service._tcp.domain,
+// e.g. a headless service of kubernetes. The records are maintained outside, so Register does nothing.
+type DNSDiscovery struct {
+	domain   string
+	interval time.Duration // how often the records are looked up by Watch
+	lookup   func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+type DNSOptions func(*DNSDiscovery)
+
+func NewDNSDiscovery(domain string, opts ...DNSOptions) *DNSDiscovery {
+	d := DNSDiscovery{
+		domain:   domain,
+		interval: defaultDNSInterval,
+		lookup:   net.DefaultResolver.LookupSRV,
+	}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return &d
+}
+
+// DNSInterval sets how often the records are looked up by Watch, 30s by default
+func DNSInterval(interval time.Duration) DNSOptions {
+	return func(d *DNSDiscovery) {
+		if interval > 0 {
+			d.interval = interval
+		}
+	}
+}
+
+// DNSLookup replaces net.DefaultResolver.LookupSRV, e.g. to use another resolver
+func DNSLookup(lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)) DNSOptions {
+	return func(d *DNSDiscovery) {
+		d.lookup = lookup
+	}
+}
+
+// Register does nothing but wait, the records are maintained outside
+func (d *DNSDiscovery) Register(ctx context.Context, service, addr string) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (d *DNSDiscovery) List(ctx context.Context, service string) ([]string, error) {
+	_, srvs, err := d.lookup(ctx, service, "tcp", d.domain)
+	if err != nil {
+		return nil, fmt.Errorf("lookup srv of %s failed: %v", service, err)
+	}
+	set := make(map[string]struct{}, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		set[net.JoinHostPort(host, fmt.Sprint(srv.Port))] = struct{}{}
+	}
+	return members(set), nil
+}
+
+// Watch looks up the records periodically, and sends the list when it changes
+func (d *DNSDiscovery) Watch(ctx context.Context, service string) (<-chan []string, error) {
+	addrs, err := d.List(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []string, 1)
+	ch <- addrs
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			latest, err := d.List(ctx, service)
+			if err != nil {
+				// keep the last list, the records may be back later
+				continue
+			}
+			if reflect.DeepEqual(latest, addrs) {
+				continue
+			}
+			addrs = latest
+			select {
+			case ch <- addrs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+var _ Discovery = (*DNSDiscovery)(nil)
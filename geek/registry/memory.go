@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryDiscovery keeps the nodes in memory, the servers and pickers in one process share it,
+// e.g. the nodes of a test cluster
+type MemoryDiscovery struct {
+	mu       sync.Mutex
+	services map[string]map[string]struct{}        // service -> addresses
+	watchers map[string]map[chan []string]struct{} // service -> channels of Watch
+}
+
+func NewMemoryDiscovery() *MemoryDiscovery {
+	return &MemoryDiscovery{
+		services: make(map[string]map[string]struct{}),
+		watchers: make(map[string]map[chan []string]struct{}),
+	}
+}
+
+// Register adds addr at once, and removes it when ctx is done
+func (d *MemoryDiscovery) Register(ctx context.Context, service, addr string) error {
+	d.mu.Lock()
+	if d.services[service] == nil {
+		d.services[service] = make(map[string]struct{})
+	}
+	d.services[service][addr] = struct{}{}
+	d.notify(service)
+	d.mu.Unlock()
+
+	<-ctx.Done()
+	d.mu.Lock()
+	delete(d.services[service], addr)
+	d.notify(service)
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *MemoryDiscovery) List(ctx context.Context, service string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return members(d.services[service]), nil
+}
+
+func (d *MemoryDiscovery) Watch(ctx context.Context, service string) (<-chan []string, error) {
+	// only the latest list matters, a stale one is replaced
+	ch := make(chan []string, 1)
+	d.mu.Lock()
+	if d.watchers[service] == nil {
+		d.watchers[service] = make(map[chan []string]struct{})
+	}
+	d.watchers[service][ch] = struct{}{}
+	ch <- members(d.services[service])
+	d.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		d.mu.Lock()
+		delete(d.watchers[service], ch)
+		close(ch)
+		d.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// lockless !!! send the latest list to the watchers of service
+func (d *MemoryDiscovery) notify(service string) {
+	addrs := members(d.services[service])
+	for ch := range d.watchers[service] {
+		select {
+		case <-ch: // drop the stale list which is not received yet
+		default:
+		}
+		ch <- addrs
+	}
+}
+
+var _ Discovery = (*MemoryDiscovery)(nil)
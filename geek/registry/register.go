@@ -2,10 +2,13 @@ package registry
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
+	"github.com/Makonike/geek-cache/geek/logger"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/endpoints"
 )
@@ -18,56 +21,372 @@ var (
 	}
 )
 
+const (
+	defaultLeaseTTL   = 2 * time.Second
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// RegistrationState is the state of a node registered to etcd
+type RegistrationState int
+
+const (
+	REGISTERED    RegistrationState = iota // the lease is kept alive
+	REREGISTERING                          // the lease is lost, the node is being registered again
+	DEREGISTERED                           // ctx of Register is done, the lease is revoked
+)
+
+func (s RegistrationState) String() string {
+	switch s {
+	case REGISTERED:
+		return "registered"
+	case REREGISTERING:
+		return "reregistering"
+	case DEREGISTERED:
+		return "deregistered"
+	default:
+		return "unknown"
+	}
+}
+
+// EtcdDiscovery registers the nodes to etcd with a lease, and watches them by the prefix service/
+type EtcdDiscovery struct {
+	config     *clientv3.Config // GlobalClientConfig is used if nil
+	tls        *tls.Config      // overrides the TLS of config if set
+	logger     logger.Logger
+	leaseTTL   time.Duration // the node is removed if the lease isn't kept alive within it
+	minBackoff time.Duration // the first delay to register again after the lease is lost
+	maxBackoff time.Duration // the delay is doubled after each failure up to it
+	onState    func(state RegistrationState, err error)
+}
+
+type EtcdOptions func(*EtcdDiscovery)
+
+var errLeaseLost = errors.New("lease lost")
+
+// NewEtcdDiscovery creates a Discovery backed by etcd, GlobalClientConfig is used if config is nil
+func NewEtcdDiscovery(config *clientv3.Config, opts ...EtcdOptions) *EtcdDiscovery {
+	d := EtcdDiscovery{
+		config:     config,
+		logger:     logger.Default(),
+		leaseTTL:   defaultLeaseTTL,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return &d
+}
+
+// EtcdLogger sets the logger, logger.Default() by default
+func EtcdLogger(l logger.Logger) EtcdOptions {
+	return func(d *EtcdDiscovery) {
+		d.logger = l
+	}
+}
+
+// EtcdTLS connects to etcd over TLS, e.g. the client certificate required by etcd
+func EtcdTLS(config *tls.Config) EtcdOptions {
+	return func(d *EtcdDiscovery) {
+		d.tls = config
+	}
+}
+
+// EtcdLeaseTTL sets the TTL of the lease, the node is removed if etcd doesn't hear from it within ttl,
+// 2 seconds by default, it's rounded down to seconds and 1 second at least
+func EtcdLeaseTTL(ttl time.Duration) EtcdOptions {
+	return func(d *EtcdDiscovery) {
+		d.leaseTTL = ttl
+	}
+}
+
+// EtcdBackoff sets the delays to register again after the lease is lost, and to list again after the watch is broken,
+// it starts from min and doubles after each failure up to max, 500ms and 10s by default
+func EtcdBackoff(min, max time.Duration) EtcdOptions {
+	return func(d *EtcdDiscovery) {
+		d.minBackoff = min
+		d.maxBackoff = max
+	}
+}
+
+// EtcdRegistrationHandler sets the callback when the state of the registration changes,
+// err is the reason of REREGISTERING, e.g. alerts when the node is out of the cluster
+func EtcdRegistrationHandler(fn func(state RegistrationState, err error)) EtcdOptions {
+	return func(d *EtcdDiscovery) {
+		d.onState = fn
+	}
+}
+
+func (d *EtcdDiscovery) newClient() (*clientv3.Client, error) {
+	config := d.config
+	if config == nil {
+		config = GlobalClientConfig
+	}
+	c := *config
+	if d.tls != nil {
+		c.TLS = d.tls
+	}
+	cli, err := clientv3.New(c)
+	if err != nil {
+		return nil, fmt.Errorf("create etcd client failed: %v", err)
+	}
+	return cli, nil
+}
+
 // 在租赁模式添加一对kv至etcd
-func etcdAdd(c *clientv3.Client, lid clientv3.LeaseID, service, addr string) error {
+func etcdAdd(ctx context.Context, c *clientv3.Client, lid clientv3.LeaseID, service, addr string) error {
 	em, err := endpoints.NewManager(c, service)
 	if err != nil {
 		return err
 	}
-	return em.AddEndpoint(c.Ctx(), service+"/"+addr, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(lid))
+	return em.AddEndpoint(ctx, service+"/"+addr, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(lid))
 }
 
-// Register register a service to etcd
-// no return if not error
-func Register(service, addr string, stop chan error) error {
-	cli, err := clientv3.New(*GlobalClientConfig)
+// Register registers addr to etcd, and revokes the lease when ctx is done.
+// The error of the first registration is returned, and after that,
+// addr is registered again with backoff whenever the lease is lost, e.g. etcd is unreachable longer than the TTL
+func (d *EtcdDiscovery) Register(ctx context.Context, service, addr string) error {
+	cli, err := d.newClient()
 	if err != nil {
-		return fmt.Errorf("create etcd client failed: %v", err)
+		return err
 	}
 	defer cli.Close()
-	// create a lease for 5 seconds
-	resp, err := cli.Grant(context.Background(), 2)
+	var leaseId clientv3.LeaseID
+	err = d.keepRegistered(ctx, service, addr, func() (<-chan struct{}, error) {
+		id, lost, err := d.register(ctx, cli, service, addr)
+		if err == nil {
+			leaseId = id
+		}
+		return lost, err
+	})
 	if err != nil {
-		return fmt.Errorf("create lease failed: %v", err)
+		return err
+	}
+	return revoke(cli, leaseId)
+}
+
+// register grants a lease, adds addr with it and keeps it alive, lost is closed when the lease is lost
+func (d *EtcdDiscovery) register(ctx context.Context, cli *clientv3.Client, service, addr string) (clientv3.LeaseID, <-chan struct{}, error) {
+	ttl := int64(d.leaseTTL / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	resp, err := cli.Grant(ctx, ttl)
+	if err != nil {
+		return 0, nil, fmt.Errorf("create lease failed: %v", err)
 	}
 	leaseId := resp.ID
 	// register service
-	err = etcdAdd(cli, leaseId, service, addr)
+	err = etcdAdd(ctx, cli, leaseId, service, addr)
 	if err != nil {
-		return fmt.Errorf("add etcd record failed: %v", err)
+		return 0, nil, fmt.Errorf("add etcd record failed: %v", err)
 	}
 	// set heartbeat
-	ch, err := cli.KeepAlive(context.Background(), leaseId)
+	ch, err := cli.KeepAlive(ctx, leaseId)
 	if err != nil {
-		return fmt.Errorf("set keepalive failed: %v", err)
+		return 0, nil, fmt.Errorf("set keepalive failed: %v", err)
 	}
-	log.Printf("[%s] register service success", addr)
+	lost := make(chan struct{})
+	go func() {
+		// 监听租约
+		for range ch {
+		}
+		close(lost)
+	}()
+	return leaseId, lost, nil
+}
+
+// keepRegistered calls register, and calls it again with backoff after the lease is lost until ctx is done.
+// Only the error of the first call is returned
+func (d *EtcdDiscovery) keepRegistered(ctx context.Context, service, addr string, register func() (lost <-chan struct{}, err error)) error {
+	lost, err := register()
+	if err != nil {
+		return err
+	}
+	d.logger.Info("register service success", "service", service, "addr", addr)
+	d.report(REGISTERED, nil)
 	for {
 		select {
-		case err := <-stop:
-			if err != nil {
-				log.Println(err)
-			}
-		case <-cli.Ctx().Done():
-			log.Println("service closed")
+		case <-ctx.Done():
+			d.report(DEREGISTERED, nil)
+			return nil
+		case <-lost:
+		}
+		if ctx.Err() != nil {
+			d.report(DEREGISTERED, nil)
 			return nil
-		case _, ok := <-ch:
-			// 监听租约
-			if !ok {
-				log.Println("keepalive channel closed")
-				_, err := cli.Revoke(context.Background(), leaseId)
-				return err
+		}
+		// the old lease is left to expire, revoking it would remove addr before it's added again
+		d.logger.Warn("lease lost, register again", "service", service, "addr", addr)
+		d.report(REREGISTERING, errLeaseLost)
+		backoff := d.minBackoff
+		for {
+			select {
+			case <-ctx.Done():
+				d.report(DEREGISTERED, nil)
+				return nil
+			case <-time.After(backoff):
+			}
+			if lost, err = register(); err == nil {
+				break
+			}
+			d.logger.Warn("failed to register again", "service", service, "addr", addr, "err", err)
+			d.report(REREGISTERING, err)
+			if backoff *= 2; backoff > d.maxBackoff {
+				backoff = d.maxBackoff
+			}
+		}
+		d.logger.Info("register service again", "service", service, "addr", addr)
+		d.report(REGISTERED, nil)
+	}
+}
+
+func (d *EtcdDiscovery) report(state RegistrationState, err error) {
+	if d.onState != nil {
+		d.onState(state, err)
+	}
+}
+
+// revoke the lease to remove the node at once, ctx of Register may be done already
+func revoke(cli *clientv3.Client, leaseId clientv3.LeaseID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := cli.Revoke(ctx, leaseId)
+	return err
+}
+
+func (d *EtcdDiscovery) List(ctx context.Context, service string) ([]string, error) {
+	cli, err := d.newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	set, _, err := etcdList(ctx, cli, service)
+	if err != nil {
+		return nil, err
+	}
+	return members(set), nil
+}
+
+// etcdList returns the nodes of service, and the revision of etcd when they're listed
+func etcdList(ctx context.Context, cli *clientv3.Client, service string) (map[string]struct{}, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	resp, err := cli.Get(ctx, service+"/", clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, 0, fmt.Errorf("list %s failed: %v", service, err)
+	}
+	set := make(map[string]struct{}, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		// key: geek-cache/127.0.0.1:8004
+		set[strings.TrimPrefix(string(kv.Key), service+"/")] = struct{}{}
+	}
+	return set, resp.Header.Revision, nil
+}
+
+// Watch sends the full list first, and then the list updated by the events of etcd in order.
+// The watch starts right after the revision of the list, so that no event is missed or applied twice,
+// and the list is fetched again when the watch is broken, e.g. the revision is compacted or etcd loses its leader
+func (d *EtcdDiscovery) Watch(ctx context.Context, service string) (<-chan []string, error) {
+	cli, err := d.newClient()
+	if err != nil {
+		return nil, err
+	}
+	set, rev, err := etcdList(ctx, cli, service)
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	ch := make(chan []string, 1)
+	ch <- members(set)
+	go func() {
+		defer cli.Close()
+		defer close(ch)
+		for {
+			d.watchFrom(ctx, cli, service, set, rev, ch)
+			if ctx.Err() != nil {
+				return
+			}
+			// resync with the full list
+			backoff := d.minBackoff
+			for {
+				if set, rev, err = etcdList(ctx, cli, service); err == nil {
+					break
+				}
+				d.logger.Warn("failed to list, retry", "service", service, "err", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				if backoff *= 2; backoff > d.maxBackoff {
+					backoff = d.maxBackoff
+				}
+			}
+			select {
+			case ch <- members(set):
+			case <-ctx.Done():
+				return
 			}
 		}
+	}()
+	return ch, nil
+}
+
+// watchFrom applies the events after rev to set and sends the list after each response,
+// until the watch is broken or ctx is done
+func (d *EtcdDiscovery) watchFrom(ctx context.Context, cli *clientv3.Client, service string, set map[string]struct{}, rev int64, ch chan<- []string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watchCh := cli.Watch(clientv3.WithRequireLeader(ctx), service+"/", clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for resp := range watchCh {
+		if err := resp.Err(); err != nil {
+			// e.g. compacted or canceled, the events may be lost
+			d.logger.Warn("watch broken, resync", "service", service, "err", err)
+			return
+		}
+		if len(resp.Events) == 0 {
+			continue
+		}
+		applyEvents(set, service, resp.Events)
+		select {
+		case ch <- members(set):
+		case <-ctx.Done():
+			return
+		}
 	}
 }
+
+// applyEvents applies the puts and deletes of the nodes to set in order
+func applyEvents(set map[string]struct{}, service string, events []*clientv3.Event) {
+	for _, ev := range events {
+		addr := strings.TrimPrefix(string(ev.Kv.Key), service+"/")
+		switch ev.Type {
+		case clientv3.EventTypePut:
+			set[addr] = struct{}{}
+		case clientv3.EventTypeDelete:
+			delete(set, addr)
+		}
+	}
+}
+
+// Register register a service to etcd
+// no return if not error, or until stop receives
+func Register(service, addr string, stop chan error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case err := <-stop:
+			if err != nil {
+				logger.Default().Error(err.Error(), "service", service, "addr", addr)
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return NewEtcdDiscovery(nil).Register(ctx, service, addr)
+}
+
+var _ Discovery = (*EtcdDiscovery)(nil)
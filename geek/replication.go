@@ -0,0 +1,164 @@
+package geek
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replication keeps each key on n peers next to it on the ring, so that the keys stay warm when their owner dies.
+// The values set or loaded by the Getter are written to all replicas, and Get falls back to the replicas
+// when the owner fails. It works with the PeerPicker which implements ReplicaPicker, and the peers implementing ReplicaPeer,
+// e.g. *ClientPicker and *Client
+func Replication(n int) GroupOptions {
+	return func(g *Group) {
+		g.replicas = n
+	}
+}
+
+// pickReplicas returns the replicas of the key, the first one is the owner, false if the replication is off
+func (g *Group) pickReplicas(key string) ([]PeerGetter, bool) {
+	if g.replicas <= 1 || g.peers == nil {
+		return nil, false
+	}
+	rp, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return nil, false
+	}
+	replicas := rp.PickReplicas(key, g.replicas)
+	return replicas, len(replicas) > 0
+}
+
+// isReplica returns true if self holds a replica of the key
+func (g *Group) isReplica(key string) bool {
+	replicas, ok := g.pickReplicas(key)
+	if !ok {
+		return false
+	}
+	for _, peer := range replicas {
+		if peer == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFromReplicas returns the replica of self if it has, or tries the replicas in order, the first one is the owner.
+// The key is loaded by self if it's the first replica alive, or all the replicas fail.
+// The main cache is looked up once, before the replicas are tried
+func (g *Group) loadFromReplicas(ctx context.Context, key string, replicas []PeerGetter) (ByteView, error) {
+	for _, peer := range replicas {
+		if peer != nil {
+			continue
+		}
+		if v, ok := g.mainCache.get(key); ok {
+			g.logger.Debug("hit", "group", g.name, "key", key)
+			atomic.AddInt64(&g.stats.cacheHits, 1)
+			return v, nil
+		}
+	}
+	for _, peer := range replicas {
+		if peer == nil {
+			// self is the first replica alive, which has missed the key
+			return g.loadLocally(ctx, key)
+		}
+		value, err := g.getFromPeer(ctx, peer, key)
+		if err == nil {
+			atomic.AddInt64(&g.stats.peerLoads, 1)
+			g.populateHotCache(key, value)
+			return value, nil
+		}
+		if errors.Is(err, ErrNotFound) {
+			// the replica has asked the Getter
+			atomic.AddInt64(&g.stats.peerLoads, 1)
+			return ByteView{}, err
+		}
+		atomic.AddInt64(&g.stats.peerErrors, 1)
+		g.logger.Warn("failed to get from replica", "group", g.name, "key", key, "err", err)
+	}
+	// self is not a replica
+	return g.getLocally(ctx, key)
+}
+
+// setReplicas writes the key-value to all replicas in parallel,
+// it succeeds if any of them keeps it, the others are logged
+func (g *Group) setReplicas(ctx context.Context, key string, value []byte, ttl time.Duration, replicas []PeerGetter) (bool, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var lastErr error
+	success := false
+	for _, peer := range replicas {
+		if peer == nil {
+			if g.setLocally(key, value, ttl) {
+				mu.Lock()
+				success = true
+				mu.Unlock()
+			}
+			continue
+		}
+		rp, ok := peer.(ReplicaPeer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(peer ReplicaPeer) {
+			defer wg.Done()
+			ok, err := peer.SetReplica(ctx, g.name, key, value, ttl)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				g.logger.Warn("failed to set replica", "group", g.name, "key", key, "err", err)
+				return
+			}
+			success = success || ok
+		}(rp)
+	}
+	wg.Wait()
+	if !success && lastErr != nil {
+		return false, lastErr
+	}
+	return success, nil
+}
+
+// replicate writes the value loaded by self to the other replicas in the background
+func (g *Group) replicate(key string, value ByteView, expirationTime time.Time) {
+	replicas, ok := g.pickReplicas(key)
+	if !ok {
+		return
+	}
+	var ttl time.Duration
+	if !expirationTime.IsZero() {
+		if ttl = time.Until(expirationTime); ttl <= 0 {
+			return
+		}
+	}
+	for _, peer := range replicas {
+		rp, ok := peer.(ReplicaPeer)
+		if !ok {
+			// self, or the peer can't keep replicas
+			continue
+		}
+		go func(peer ReplicaPeer) {
+			if _, err := peer.SetReplica(context.Background(), g.name, key, value.b, ttl); err != nil {
+				g.logger.Warn("failed to replicate", "group", g.name, "key", key, "err", err)
+			}
+		}(rp)
+	}
+}
+
+// setLocally puts the key-value into the cache of self, the key never expires if ttl is 0
+func (g *Group) setLocally(key string, value []byte, ttl time.Duration) bool {
+	if g.isClosed() {
+		return false
+	}
+	var expirationTime time.Time
+	if ttl > 0 {
+		expirationTime = time.Now().Add(ttl)
+	}
+	g.removeHotCache(key)
+	g.populateCache(key, ByteView{cloneBytes(value)}, expirationTime)
+	return true
+}
@@ -2,32 +2,55 @@ package geek
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/Makonike/geek-cache/geek/utils"
-	"log"
+	"io"
 	"net"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/Makonike/geek-cache/geek/logger"
 	pb "github.com/Makonike/geek-cache/geek/pb"
 	registy "github.com/Makonike/geek-cache/geek/registry"
+	"go.opentelemetry.io/otel/trace"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	defaultServiceName = "geek-cache"
 	defaultAddr        = "127.0.0.1:7654"
+	defaultDrainDelay  = time.Second
+	drainPollInterval  = 50 * time.Millisecond // how often Stop lists the discovery until self is removed
 )
 
 type Server struct {
 	pb.UnimplementedGroupCacheServer
-	self       string     // self ip
-	sname      string     // name of service
-	status     bool       // true if the server is running
-	mu         sync.Mutex // guards
-	stopSignal chan error // signal to stop
+	self         string             // self ip
+	sname        string             // name of service
+	status       bool               // true if the server is running
+	mu           sync.Mutex         // guards
+	cancel       context.CancelFunc // stops the registration
+	deregistered chan struct{}      // closed when self is deregistered
+	grpcServer   *grpc.Server
+	discovery    registy.Discovery // registers self, etcd by default
+	drainDelay   time.Duration     // how long to wait for the peers to see the deregistration
+	groups       func(name string) *Group
+	grpcOpts     []grpc.ServerOption
+	logger       logger.Logger
+	latency      rpcHistograms // latency of the calls served by method
+	tracer       trace.Tracer
+	auth         Authenticator // no authentication if nil
+	acl          ACL
+	reflection   *bool // registered if there's no auth by default
 }
 
 type ServerOptions func(*Server)
@@ -39,8 +62,14 @@ func NewServer(self string, opts ...ServerOptions) (*Server, error) {
 		return nil, fmt.Errorf("invalid address: %v", self)
 	}
 	s := Server{
-		self:  self,
-		sname: defaultServiceName,
+		self:       self,
+		sname:      defaultServiceName,
+		discovery:  registy.NewEtcdDiscovery(nil),
+		drainDelay: defaultDrainDelay,
+		groups:     GetGroup,
+		logger:     logger.Default(),
+		latency:    newRPCHistograms(),
+		tracer:     newTracer(nil),
 	}
 	for _, opt := range opts {
 		opt(&s)
@@ -54,24 +83,107 @@ func ServiceName(sname string) ServerOptions {
 	}
 }
 
+// ServerDiscovery sets where the server registers itself, etcd by default,
+// it should be the same as the PickerDiscovery of the peers
+func ServerDiscovery(d registy.Discovery) ServerOptions {
+	return func(s *Server) {
+		s.discovery = d
+	}
+}
+
+// ServerLogger sets the logger of the server, logger.Default() by default
+func ServerLogger(l logger.Logger) ServerOptions {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// ServerTracerProvider sets where the spans of the calls served are created,
+// they're the children of the spans of the clients. The spans are not recorded by default
+func ServerTracerProvider(tp trace.TracerProvider) ServerOptions {
+	return func(s *Server) {
+		s.tracer = newTracer(tp)
+	}
+}
+
+// ServerDrainDelay sets how long Stop waits for the peers to see the deregistration at most
+// before draining the in-flight calls, 1s by default. Stop waits until the discovery doesn't list self,
+// so it should be longer than the delay of the discovery, e.g. the interval of DNS.
+func ServerDrainDelay(delay time.Duration) ServerOptions {
+	return func(s *Server) {
+		s.drainDelay = delay
+	}
+}
+
+// ServerGroupsForTesting sets how the groups are found by name, GetGroup by default,
+// so that the groups of PrivateForTesting are served. It's meant for geektest, and not for the applications
+func ServerGroupsForTesting(lookup func(name string) *Group) ServerOptions {
+	return func(s *Server) {
+		s.groups = lookup
+	}
+}
+
+// ServerTLS serves over TLS instead of plaintext, e.g. the config of TLSFiles.ServerConfig,
+// the peers should dial with ClientTLS
+func ServerTLS(config *tls.Config) ServerOptions {
+	return func(s *Server) {
+		s.grpcOpts = append(s.grpcOpts, grpc.Creds(credentials.NewTLS(config)))
+	}
+}
+
+// ServerAuth authenticates the callers by auth, and checks their permissions on the groups by acl,
+// all authenticated callers are allowed if acl is nil.
+// The peers should sign their calls by PickerAuth, or present client certificates for MTLSAuth
+func ServerAuth(auth Authenticator, acl ACL) ServerOptions {
+	return func(s *Server) {
+		s.auth = auth
+		s.acl = acl
+	}
+}
+
+// ServerReflection sets whether the reflection service of grpc is registered,
+// it's registered by default unless ServerAuth is set, and served without authentication if enabled with it
+func ServerReflection(enabled bool) ServerOptions {
+	return func(s *Server) {
+		s.reflection = &enabled
+	}
+}
+
+// ServerKeepalivePolicy sets how often the clients are permitted to send keepalive pings,
+// it should match the ClientKeepalive of the peers
+func ServerKeepalivePolicy(ep keepalive.EnforcementPolicy) ServerOptions {
+	return func(s *Server) {
+		s.grpcOpts = append(s.grpcOpts, grpc.KeepaliveEnforcementPolicy(ep))
+	}
+}
+
+// Stats returns the latency of the calls served
+func (s *Server) Stats() RPCStats {
+	return s.latency.stats()
+}
+
 // Log info
 func (s *Server) Log(format string, path ...interface{}) {
-	log.Printf("[Server %s] %s", s.self, fmt.Sprintf(format, path...))
+	s.logger.Info(fmt.Sprintf(format, path...), "self", s.self)
 }
 
 func (s *Server) Get(ctx context.Context, in *pb.Request) (*pb.ResponseForGet, error) {
+	defer s.latency.observe("get", time.Now())
 	group, key := in.GetGroup(), in.GetKey()
 	out := &pb.ResponseForGet{}
-	log.Printf("[Geek-Cache %s] Recv RPC Request for get- (%s)/(%s)", s.self, group, key)
+	s.logger.Debug("recv rpc request", "self", s.self, "method", "get", "group", group, "key", key)
 
 	if key == "" {
 		return out, fmt.Errorf("key required")
 	}
-	g := GetGroup(group)
+	g := s.groups(group)
 	if g == nil {
 		return out, fmt.Errorf("group not found")
 	}
-	view, err := g.Get(key)
+	view, err := g.GetContext(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return out, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return out, err
 	}
@@ -80,70 +192,352 @@ func (s *Server) Get(ctx context.Context, in *pb.Request) (*pb.ResponseForGet, e
 }
 
 func (s *Server) Delete(ctx context.Context, in *pb.Request) (*pb.ResponseForDelete, error) {
+	defer s.latency.observe("delete", time.Now())
 	group, key := in.GetGroup(), in.GetKey()
 	out := &pb.ResponseForDelete{}
-	log.Printf("[Geek-Cache %s] Recv RPC Request for delete - (%s)/(%s)", s.self, group, key)
+	s.logger.Debug("recv rpc request", "self", s.self, "method", "delete", "group", group, "key", key)
 
 	if key == "" {
 		return out, fmt.Errorf("key required")
 	}
-	g := GetGroup(group)
+	g := s.groups(group)
 	if g == nil {
 		return out, fmt.Errorf("group not found")
 	}
-	isSuccess, err := g.Delete(key)
-	if err != nil {
+	isSuccess, err := g.DeleteContext(ctx, key)
+	if failed, ok := invalidationErrors(err); ok {
+		// the key is deleted, but some peers didn't acknowledge the invalidation
+		out.InvalidationErrors = failed
+	} else if err != nil {
 		return out, err
 	}
 	out.Value = isSuccess
 	return out, nil
 }
 
+// invalidationErrors returns the errors of the peers by address if err is an *InvalidationError
+func invalidationErrors(err error) (map[string]string, bool) {
+	e, ok := err.(*InvalidationError)
+	if !ok {
+		return nil, false
+	}
+	failed := make(map[string]string, len(e.Failed))
+	for addr, err := range e.Failed {
+		failed[addr] = err.Error()
+	}
+	return failed, true
+}
+
+func (s *Server) Invalidate(ctx context.Context, in *pb.Request) (*pb.ResponseForInvalidate, error) {
+	defer s.latency.observe("invalidate", time.Now())
+	group, key := in.GetGroup(), in.GetKey()
+	out := &pb.ResponseForInvalidate{}
+	s.logger.Debug("recv rpc request", "self", s.self, "method", "invalidate", "group", group, "key", key)
+
+	if key == "" {
+		return out, fmt.Errorf("key required")
+	}
+	g := s.groups(group)
+	if g == nil {
+		// nothing cached for the group
+		out.Value = true
+		return out, nil
+	}
+	g.invalidateLocally(key)
+	out.Value = true
+	return out, nil
+}
+
+func (s *Server) Set(ctx context.Context, in *pb.SetRequest) (*pb.ResponseForSet, error) {
+	method := "set"
+	if in.GetReplica() {
+		method = "setreplica"
+	}
+	defer s.latency.observe(method, time.Now())
+	group, key := in.GetGroup(), in.GetKey()
+	out := &pb.ResponseForSet{}
+	s.logger.Debug("recv rpc request", "self", s.self, "method", method, "group", group, "key", key)
+
+	if key == "" {
+		return out, fmt.Errorf("key required")
+	}
+	g := s.groups(group)
+	if g == nil {
+		return out, fmt.Errorf("group not found")
+	}
+	ttl := time.Duration(in.GetTtl()) * time.Millisecond
+	if in.GetReplica() {
+		out.Value = g.setLocally(key, in.GetValue(), ttl)
+		return out, nil
+	}
+	isSuccess, err := g.SetContext(ctx, key, in.GetValue(), ttl)
+	if failed, ok := invalidationErrors(err); ok {
+		// the key is set, but some peers didn't acknowledge the invalidation
+		out.InvalidationErrors = failed
+	} else if err != nil {
+		return out, err
+	}
+	out.Value = isSuccess
+	return out, nil
+}
+
+func (s *Server) MGet(ctx context.Context, in *pb.BatchRequest) (*pb.ResponseForMGet, error) {
+	defer s.latency.observe("mget", time.Now())
+	group, keys := in.GetGroup(), in.GetKeys()
+	out := &pb.ResponseForMGet{}
+	s.logger.Debug("recv rpc request", "self", s.self, "method", "mget", "group", group, "keys", len(keys))
+
+	g := s.groups(group)
+	if g == nil {
+		return out, fmt.Errorf("group not found")
+	}
+	for _, r := range g.GetManyContext(ctx, keys) {
+		result := &pb.GetResult{Key: r.Key}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+			result.NotFound = errors.Is(r.Err, ErrNotFound)
+		} else {
+			result.Value = r.Value.ByteSLice()
+		}
+		out.Results = append(out.Results, result)
+	}
+	return out, nil
+}
+
+func (s *Server) MDelete(ctx context.Context, in *pb.BatchRequest) (*pb.ResponseForMDelete, error) {
+	defer s.latency.observe("mdelete", time.Now())
+	group, keys := in.GetGroup(), in.GetKeys()
+	out := &pb.ResponseForMDelete{}
+	s.logger.Debug("recv rpc request", "self", s.self, "method", "mdelete", "group", group, "keys", len(keys))
+
+	g := s.groups(group)
+	if g == nil {
+		return out, fmt.Errorf("group not found")
+	}
+	for _, r := range g.DeleteManyContext(ctx, keys) {
+		result := &pb.DeleteResult{Key: r.Key, Value: r.Success}
+		if failed, ok := invalidationErrors(r.Err); ok {
+			result.InvalidationErrors = failed
+		} else if r.Err != nil {
+			result.Error = r.Err.Error()
+		}
+		out.Results = append(out.Results, result)
+	}
+	return out, nil
+}
+
+// Handoff keeps the entries from the previous owners after the ring changed
+func (s *Server) Handoff(stream pb.GroupCache_HandoffServer) error {
+	defer s.latency.observe("handoff", time.Now())
+	out := &pb.ResponseForHandoff{}
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			s.logger.Debug("recv handoff", "self", s.self, "accepted", out.Accepted)
+			return stream.SendAndClose(out)
+		}
+		if err != nil {
+			return err
+		}
+		if in.GetKey() == "" {
+			continue
+		}
+		if !s.allowed(stream.Context(), in.GetGroup(), WRITE) {
+			return status.Errorf(codes.PermissionDenied, "not allowed to hand off to group %s", in.GetGroup())
+		}
+		g := s.groups(in.GetGroup())
+		if g == nil {
+			return fmt.Errorf("group not found")
+		}
+		var expirationTime time.Time
+		if in.GetExpire() > 0 {
+			expirationTime = time.UnixMilli(in.GetExpire())
+		}
+		if g.acceptHandoff(in.GetKey(), in.GetValue(), expirationTime) {
+			out.Accepted++
+		}
+	}
+}
+
+func (s *Server) Peek(ctx context.Context, in *pb.Request) (*pb.ResponseForPeek, error) {
+	defer s.latency.observe("peek", time.Now())
+	group, key := in.GetGroup(), in.GetKey()
+	out := &pb.ResponseForPeek{}
+	s.logger.Debug("recv rpc request", "self", s.self, "method", "peek", "group", group, "key", key)
+
+	if key == "" {
+		return out, fmt.Errorf("key required")
+	}
+	g := s.groups(group)
+	if g == nil {
+		return out, fmt.Errorf("group not found")
+	}
+	view, expirationTime, ok := g.peekLocally(key)
+	if !ok {
+		return out, status.Error(codes.NotFound, ErrNotFound.Error())
+	}
+	out.Value = view.ByteSLice()
+	if !expirationTime.IsZero() {
+		out.Expire = expirationTime.UnixMilli()
+	}
+	return out, nil
+}
+
+// allowed returns true if the caller of the stream has perm on group by the acl of ServerAuth
+func (s *Server) allowed(ctx context.Context, group string, perm Permission) bool {
+	if s.auth == nil || s.acl == nil {
+		return true
+	}
+	principal, _ := PrincipalFromContext(ctx)
+	return s.acl.Allowed(principal, group, perm)
+}
+
+// Start listens on the port of self and serves until Stop
 func (s *Server) Start() error {
+	port := strings.Split(s.self, ":")[1]
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", port, err)
+	}
+	return s.Serve(l)
+}
+
+// Serve serves on l until Stop, self is registered while serving
+func (s *Server) Serve(l net.Listener) error {
 	s.mu.Lock()
 	if s.status {
 		s.mu.Unlock()
+		_ = l.Close()
 		return fmt.Errorf("server already running")
 	}
 	s.status = true
-	s.stopSignal = make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	deregistered := make(chan struct{})
+	s.deregistered = deregistered
 
-	port := strings.Split(s.self, ":")[1]
-	l, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %v", port, err)
+	interceptors := []grpc.UnaryServerInterceptor{tracingUnaryServerInterceptor(s.tracer), forwardingUnaryServerInterceptor()}
+	var streamInterceptors []grpc.StreamServerInterceptor
+	enableReflection := (s.reflection == nil && s.auth == nil) || (s.reflection != nil && *s.reflection)
+	if s.auth != nil {
+		interceptors = append(interceptors, authUnaryServerInterceptor(s.auth, s.acl))
+		streamInterceptors = append(streamInterceptors, authStreamServerInterceptor(s.auth, enableReflection))
 	}
-	grpcServer := grpc.NewServer()
+	opts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}, s.grpcOpts...)
+	grpcServer := grpc.NewServer(opts...)
+	s.grpcServer = grpcServer
 	pb.RegisterGroupCacheServer(grpcServer, s)
-	// 启动 reflection 反射服务
-	reflection.Register(grpcServer)
+	if enableReflection {
+		// 启动 reflection 反射服务
+		reflection.Register(grpcServer)
+	}
+	registerErr := make(chan error, 1)
 	go func() {
-		err := registy.Register(s.sname, s.self, s.stopSignal)
-		if err != nil {
-			log.Fatalf(err.Error())
+		err := s.discovery.Register(ctx, s.sname, s.self)
+		close(deregistered)
+		if ctx.Err() != nil {
+			// deregistered by Stop, which drains the calls
+			s.logger.Info("revoke service ok", "self", s.self)
+			return
 		}
-		close(s.stopSignal)
-		err = l.Close()
+		// the server is stopped if it can't be registered
 		if err != nil {
-			log.Fatalf(err.Error())
+			registerErr <- err
 		}
-		log.Printf("[%s] Revoke service and close tcp socket ok", s.self)
+		s.mu.Lock()
+		s.status = false
+		s.mu.Unlock()
+		grpcServer.Stop()
 	}()
 
 	s.mu.Unlock()
-	if err := grpcServer.Serve(l); s.status && err != nil {
-		return fmt.Errorf("failed to serve on %s: %v", port, err)
+	err := grpcServer.Serve(l)
+	select {
+	case err := <-registerErr:
+		return fmt.Errorf("failed to register %s: %v", s.self, err)
+	default:
+	}
+	if err != nil && ctx.Err() == nil {
+		cancel()
+		return fmt.Errorf("failed to serve on %s: %v", l.Addr(), err)
 	}
 	return nil
 }
 
-func (s *Server) Stop() {
+// Stop shuts down the server gracefully, so that the peers don't see errors:
+// 1. deregister self from the discovery
+// 2. wait for the discovery not to list self, so that the peers stop sending calls, drainDelay at most
+// 3. wait for the in-flight calls to complete
+// 4. close the tcp socket and connections
+// The calls not completed when ctx is done are canceled, and ctx.Err() is returned.
+func (s *Server) Stop(ctx context.Context) error {
 	s.mu.Lock()
 	if !s.status {
 		s.mu.Unlock()
-		return
+		return nil
 	}
-	s.stopSignal <- nil
 	s.status = false
+	s.cancel()
+	grpcServer, deregistered := s.grpcServer, s.deregistered
 	s.mu.Unlock()
+
+	select {
+	case <-deregistered:
+	case <-ctx.Done():
+		grpcServer.Stop()
+		return ctx.Err()
+	}
+	if err := s.waitUnlisted(ctx); err != nil {
+		grpcServer.Stop()
+		return err
+	}
+	drained := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		s.logger.Info("drain calls and close tcp socket ok", "self", s.self)
+		return nil
+	case <-ctx.Done():
+		grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
+// waitUnlisted lists the discovery every drainPollInterval until self is not listed, or drainDelay passes
+func (s *Server) waitUnlisted(ctx context.Context) error {
+	pollCtx, cancel := context.WithTimeout(ctx, s.drainDelay)
+	defer cancel()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if !s.listed(pollCtx) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-pollCtx.Done():
+			// drainDelay passed if ctx is not done
+			return ctx.Err()
+		}
+	}
+}
+
+// listed returns true if the discovery lists self, or it can't be listed
+func (s *Server) listed(ctx context.Context) bool {
+	addrs, err := s.discovery.List(ctx, s.sname)
+	if err != nil {
+		return true
+	}
+	for _, addr := range addrs {
+		if addr == s.self {
+			return true
+		}
+	}
+	return false
 }
@@ -1,10 +1,13 @@
 package geek
 
 import (
+	"context"
 	"fmt"
+	"github.com/Makonike/geek-cache/geek/registry"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"math/rand"
+	"net"
 	"reflect"
 	"testing"
 	"time"
@@ -31,7 +34,7 @@ func TestServer(t *testing.T) {
 	addr := fmt.Sprintf("localhost:%d", port)
 
 	// 添加peerPicker
-	picker := NewClientPicker(addr)
+	picker := NewClientPicker(addr, PickerDiscovery(registry.NewStaticDiscovery(addr)))
 
 	g.RegisterPeers(picker)
 
@@ -50,3 +53,95 @@ func TestServer(t *testing.T) {
 		t.Errorf("Unknown not exists, but got %s", view.String())
 	}
 }
+
+func TestServer_Stop(t *testing.T) {
+	a := assert.New(t)
+	loading := make(chan struct{}, 1)
+	NewGroup("drain", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, bool, time.Time) {
+			loading <- struct{}{}
+			select {
+			case <-time.After(300 * time.Millisecond):
+				return []byte(key), true, time.Time{}
+			case <-ctx.Done():
+				return nil, false, time.Time{}
+			}
+		}))
+	start := func() (*Server, *Client, chan error, *registry.MemoryDiscovery) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		a.Nil(err)
+		d := registry.NewMemoryDiscovery()
+		s, err := NewServer(l.Addr().String(), ServerDiscovery(d), ServerDrainDelay(50*time.Millisecond))
+		a.Nil(err)
+		served := make(chan error, 1)
+		go func() {
+			served <- s.Serve(l)
+		}()
+		client, err := NewClient(l.Addr().String(), defaultServiceName)
+		a.Nil(err)
+		return s, client, served, d
+	}
+
+	// the in-flight call completes
+	s, client, served, d := start()
+	defer client.Close()
+	got := make(chan error, 1)
+	go func() {
+		v, err := client.Get(context.Background(), "drain", "Tom")
+		a.Equal("Tom", string(v))
+		got <- err
+	}()
+	<-loading
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	a.Nil(s.Stop(ctx))
+	a.Nil(<-got)
+	a.Nil(<-served)
+	addrs, _ := d.List(ctx, defaultServiceName)
+	a.Empty(addrs)
+	// stop again
+	a.Nil(s.Stop(ctx))
+
+	// the call is canceled when ctx is done
+	s, client2, served, _ := start()
+	defer client2.Close()
+	go func() {
+		_, err := client2.Get(context.Background(), "drain", "Jack")
+		got <- err
+	}()
+	<-loading
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	a.ErrorIs(s.Stop(ctx), context.DeadlineExceeded)
+	a.NotNil(<-got)
+	a.Nil(<-served)
+}
+
+func TestServer_StopWaitsUnlisted(t *testing.T) {
+	a := assert.New(t)
+	stop := func(d registry.Discovery) time.Duration {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		a.Nil(err)
+		if d == nil {
+			// the static list keeps self after the deregistration
+			d = registry.NewStaticDiscovery(l.Addr().String())
+		}
+		s, err := NewServer(l.Addr().String(), ServerDiscovery(d), ServerDrainDelay(500*time.Millisecond))
+		a.Nil(err)
+		served := make(chan error, 1)
+		go func() {
+			served <- s.Serve(l)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		a.Nil(s.Stop(context.Background()))
+		a.Nil(<-served)
+		return time.Since(start)
+	}
+	// stopped once the discovery doesn't list self
+	a.Less(stop(registry.NewMemoryDiscovery()), 400*time.Millisecond)
+	// the drain delay is the upper bound
+	took := stop(nil)
+	a.GreaterOrEqual(took, 500*time.Millisecond)
+	a.Less(took, 2*time.Second)
+}
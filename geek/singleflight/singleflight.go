@@ -1,14 +1,16 @@
 package singleflight
 
 import (
+	"context"
 	"sync"
 )
 
 // 代表正在进行或已结束的请求
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done     chan struct{} // closed when the request is completed
+	val      interface{}
+	err      error
+	canceled bool // the context of the request was done when it completed
 }
 
 // Group manages all kinds of calls
@@ -19,6 +21,15 @@ type Group struct {
 
 // Do 针对相同的key，保证多次调用Do()，都只会调用一次fn
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return g.DoContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// DoContext is like Do, fn is called with the ctx of the first caller.
+// Callers waiting for the request return when their ctx is done,
+// and call fn again if the request failed because the first caller was canceled.
+func (g *Group) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
 	// lock protects for m in concurrent calls
 	g.mu.Lock()
 	if g.m == nil {
@@ -26,19 +37,27 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	}
 	if c, ok := g.m[key]; ok {
 		g.mu.Unlock()
-		c.wg.Wait()         // wait for doing request
+		select {
+		case <-c.done: // wait for doing request
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if c.canceled && ctx.Err() == nil {
+			// the request was canceled by others, have a try again
+			return g.DoContext(ctx, key, fn)
+		}
 		return c.val, c.err // request completed, return result
 	}
-	c := new(call) // a new request, and this is the first request for this key
-	c.wg.Add(1)
+	c := &call{done: make(chan struct{})} // a new request, and this is the first request for this key
 	g.m[key] = c
 	g.mu.Unlock()
 
-	c.val, c.err = fn() // with callback
-	c.wg.Done()         // this request was completed, and other requests for this key will be continue
+	c.val, c.err = fn(ctx) // with callback
+	c.canceled = c.err != nil && ctx.Err() != nil
 	g.mu.Lock()
 	delete(g.m, key)
 	g.mu.Unlock()
+	close(c.done) // this request was completed, and other requests for this key will be continue
 
 	return c.val, c.err
 }
@@ -0,0 +1,83 @@
+package singleflight
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	a := assert.New(t)
+	var g Group
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(100 * time.Millisecond)
+				return "bar", nil
+			})
+			a.Nil(err)
+			a.Equal("bar", v)
+		}()
+	}
+	wg.Wait()
+	a.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+// 等待的调用者可以被自己的ctx取消
+func TestDoContext_WaiterCanceled(t *testing.T) {
+	a := assert.New(t)
+	var g Group
+	release := make(chan struct{})
+	go func() {
+		_, _ = g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+			<-release
+			return "bar", nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		return "other", nil
+	})
+	a.Equal(context.DeadlineExceeded, err)
+	close(release)
+}
+
+// 第一个调用者被取消时, 其他调用者重新执行
+func TestDoContext_LeaderCanceled(t *testing.T) {
+	a := assert.New(t)
+	var g Group
+	ctx, cancel := context.WithCancel(context.Background())
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return "bar", nil
+		}
+	}
+	done := make(chan error)
+	go func() {
+		_, err := g.DoContext(ctx, "key", fn)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	v, err := g.DoContext(context.Background(), "key", fn)
+	a.Nil(err)
+	a.Equal("bar", v)
+	a.Equal(context.Canceled, <-done)
+}
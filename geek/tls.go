@@ -0,0 +1,155 @@
+package geek
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSFiles are the PEM files of TLS, they're reloaded when changed, so that the certificates can be rotated without a restart
+type TLSFiles struct {
+	CertFile string // the certificate of self
+	KeyFile  string // the private key of CertFile
+	CAFile   string // verifies the certificates of the other side, the system roots are used if empty
+}
+
+// ServerConfig returns the config for ServerTLS,
+// the clients must present a certificate signed by CAFile if mutual is true
+func (f TLSFiles) ServerConfig(mutual bool) (*tls.Config, error) {
+	if f.CertFile == "" || f.KeyFile == "" {
+		return nil, errors.New("tls: the certificate and key of the server are required")
+	}
+	if mutual && f.CAFile == "" {
+		return nil, errors.New("tls: the CA to verify the clients is required")
+	}
+	r := &tlsReloader{files: f}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	outer := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// grpc only adds h2 to its own copy of the config
+		NextProtos: []string{"h2"},
+	}
+	// the config is built for each handshake with the latest files, it replaces the outer one entirely
+	outer.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, pool := r.get()
+		config := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*cert},
+			NextProtos:   outer.NextProtos,
+		}
+		if mutual {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+			config.ClientCAs = pool
+		}
+		return config, nil
+	}
+	return outer, nil
+}
+
+// ClientConfig returns the config for ClientTLS, CertFile and KeyFile are presented to the servers which require mutual TLS
+func (f TLSFiles) ClientConfig() (*tls.Config, error) {
+	r := &tlsReloader{files: f}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if f.CertFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.get()
+			return cert, nil
+		}
+	}
+	if f.CAFile != "" {
+		// RootCAs can't be changed after the config is used, so the server is verified by the latest CA here
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, pool := r.get()
+			opts := x509.VerifyOptions{
+				DNSName:       cs.ServerName,
+				Roots:         pool,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+	return config, nil
+}
+
+// tlsReloader keeps the files loaded, and loads them again when their modification time changes
+type tlsReloader struct {
+	files   TLSFiles
+	mu      sync.Mutex // guards
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time // the latest modification time of the files
+}
+
+func (r *tlsReloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if modTime, err := r.latestModTime(); err == nil && modTime.After(r.modTime) {
+		// the loaded files are kept if it fails, e.g. the cert is written but the key is not yet
+		_ = r.load()
+	}
+	return r.cert, r.pool
+}
+
+func (r *tlsReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load()
+}
+
+func (r *tlsReloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	if r.files.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return fmt.Errorf("tls: failed to load key pair: %v", err)
+		}
+		r.cert = &cert
+	}
+	if r.files.CAFile != "" {
+		pem, err := os.ReadFile(r.files.CAFile)
+		if err != nil {
+			return fmt.Errorf("tls: failed to read CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificate found in %s", r.files.CAFile)
+		}
+		r.pool = pool
+	}
+	r.modTime = modTime
+	return nil
+}
+
+func (r *tlsReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("tls: %v", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
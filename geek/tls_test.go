@@ -0,0 +1,191 @@
+package geek
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCA signs the certificates of the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "geek-cache test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// writeCA writes the CA to dir, and returns the path
+func (ca *testCA) writeCA(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "ca.pem")
+	writePEM(t, path, "CERTIFICATE", ca.cert.Raw)
+	return path
+}
+
+// issue writes a certificate for 127.0.0.1 to dir, and returns the files of it
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) TLSFiles {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := TLSFiles{
+		CertFile: filepath.Join(dir, name+".pem"),
+		KeyFile:  filepath.Join(dir, name+"-key.pem"),
+		CAFile:   ca.writeCA(t, dir),
+	}
+	writePEM(t, files.CertFile, "CERTIFICATE", der)
+	writePEM(t, files.KeyFile, "EC PRIVATE KEY", keyDer)
+	return files
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsGet returns the error of a call to addr by the client with opts
+func tlsGet(addr string, opts ...ClientOptions) error {
+	client, err := NewClient(addr, defaultServiceName, append(opts, ClientTimeout(time.Second))...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	_, err = client.Get(context.Background(), "tls", "Tom")
+	return err
+}
+
+func TestServerTLS(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverFiles := ca.issue(t, dir, "server", 2)
+	config, err := serverFiles.ServerConfig(false)
+	a.Nil(err)
+	_, addr := serveGroup(t, newDBGroup("tls"), ServerTLS(config))
+
+	clientConfig, err := TLSFiles{CAFile: serverFiles.CAFile}.ClientConfig()
+	a.Nil(err)
+	a.Nil(tlsGet(addr, ClientTLS(clientConfig)))
+	// h2 is negotiated by ALPN
+	clientConfig.NextProtos = []string{"h2"}
+	conn, err := tls.Dial("tcp", addr, clientConfig)
+	a.Nil(err)
+	a.Equal("h2", conn.ConnectionState().NegotiatedProtocol)
+	_ = conn.Close()
+	// plaintext is refused
+	a.NotNil(tlsGet(addr))
+	// the server is not trusted without the CA
+	other := newTestCA(t)
+	clientConfig, err = TLSFiles{CAFile: other.writeCA(t, t.TempDir())}.ClientConfig()
+	a.Nil(err)
+	a.NotNil(tlsGet(addr, ClientTLS(clientConfig)))
+}
+
+func TestServerTLS_Mutual(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	config, err := ca.issue(t, dir, "server", 2).ServerConfig(true)
+	a.Nil(err)
+	_, addr := serveGroup(t, newDBGroup("tls"), ServerTLS(config))
+
+	// the peers present their certificates
+	clientConfig, err := ca.issue(t, dir, "client", 3).ClientConfig()
+	a.Nil(err)
+	a.Nil(tlsGet(addr, ClientTLS(clientConfig)))
+	// refused without a certificate
+	clientConfig, err = TLSFiles{CAFile: filepath.Join(dir, "ca.pem")}.ClientConfig()
+	a.Nil(err)
+	a.NotNil(tlsGet(addr, ClientTLS(clientConfig)))
+}
+
+func TestTLSFiles_Reload(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	files := ca.issue(t, dir, "server", 2)
+	config, err := files.ServerConfig(false)
+	a.Nil(err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	a.Nil(err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+	clientConfig, err := TLSFiles{CAFile: files.CAFile}.ClientConfig()
+	a.Nil(err)
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", l.Addr().String(), clientConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	a.Equal(int64(2), serial())
+
+	// rotate the certificate without a restart
+	ca.issue(t, dir, "server", 4)
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{files.CertFile, files.KeyFile} {
+		a.Nil(os.Chtimes(name, later, later))
+	}
+	a.Equal(int64(4), serial())
+}
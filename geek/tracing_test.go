@@ -0,0 +1,53 @@
+package geek
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	a := assert.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, addr := serveGroup(t, newDBGroup("tracing", GroupTracerProvider(tp)), ServerTracerProvider(tp))
+	client, err := NewClient(addr, defaultServiceName, ClientTracerProvider(tp))
+	a.Nil(err)
+	defer client.Close()
+
+	g := newDBGroup("tracing", GroupTracerProvider(tp))
+	g.RegisterPeers(&singlePeerPicker{peer: client})
+	v, err := g.Get("Tom")
+	a.Nil(err)
+	a.Equal("dbTom", v.String())
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"geek.Group.load", "geek.Group.singleflight", "geek.Group.pickPeer",
+		"geek.Group.getFromPeer", "/pb.GroupCache/Get", "geek.Group.getLocally"} {
+		a.Contains(spans, name)
+	}
+	// one trace spans both nodes
+	load := spans["geek.Group.load"]
+	for _, span := range recorder.Ended() {
+		a.Equal(load.SpanContext().TraceID(), span.SpanContext().TraceID(), span.Name())
+	}
+	// the span of the server is the child of the span of the client
+	var caller, callee sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "/pb.GroupCache/Get" {
+			if span.Parent().IsRemote() {
+				callee = span
+			} else {
+				caller = span
+			}
+		}
+	}
+	a.NotNil(caller)
+	a.NotNil(callee)
+	a.Equal(caller.SpanContext().SpanID(), callee.Parent().SpanID())
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/Makonike/geek-cache/geek"
@@ -40,11 +44,23 @@ func main() {
 	picker := geek.NewClientPicker(addr)
 	g.RegisterPeers(picker)
 
-	for {
-		err = server.Start()
-		if err != nil {
+	stopped := make(chan struct{})
+	go func() {
+		// drain the calls before exiting, e.g. in rolling deploys
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Stop(ctx); err != nil {
 			log.Println(err.Error())
-			return
 		}
+		_ = picker.Close()
+		close(stopped)
+	}()
+	if err = server.Start(); err != nil {
+		log.Println(err.Error())
+		return
 	}
+	<-stopped
 }